@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/joshjennings98/backend-demo/server/v2/server"
+)
+
+var (
+	exportHTML   string
+	exportScript string
+)
+
+func init() {
+	exportCmd.Flags().StringVar(&exportHTML, "html", "", "File to write the presentation to as a single HTML page")
+	exportCmd.Flags().StringVar(&exportScript, "script", "", "File to write the commands of the presentation to as a shell script")
+	_ = viper.BindPFlag("html", exportCmd.Flags().Lookup("html"))
+	_ = viper.BindPFlag("script", exportCmd.Flags().Lookup("script"))
+
+	rootCmd.AddCommand(exportCmd)
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export a presentation to share after the talk",
+	Long:  "Export a presentation as a single HTML page that can be viewed without the server, with any recorded output of its commands, or its commands as a shell script",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		if commandFile == "" {
+			err = errors.New("commands file must be provided via -c/-commands")
+			return
+		}
+		if exportHTML == "" && exportScript == "" {
+			err = errors.New("file to export to must be provided via --html or --script")
+			return
+		}
+
+		logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+		if exportHTML != "" {
+			err = writeExport(exportHTML, func(w *bufio.Writer) error {
+				return server.ExportHTML(logger, commandFile, w, serverOptions(cmd)...)
+			})
+			if err != nil {
+				return
+			}
+			logger.Info("exported presentation", "file", exportHTML)
+		}
+
+		if exportScript != "" {
+			err = writeExport(exportScript, func(w *bufio.Writer) error {
+				return server.ExportScript(logger, commandFile, w, serverOptions(cmd)...)
+			})
+			if err != nil {
+				return
+			}
+
+			// the script is meant to be run
+			if err = os.Chmod(exportScript, 0o755); err != nil { //nolint:gosec // scripts need to be executable
+				err = fmt.Errorf("could not make %v executable: %w", exportScript, err)
+				return
+			}
+			logger.Info("exported commands", "file", exportScript)
+		}
+
+		return
+	},
+}
+
+// writeExport writes an export to a file, removing the file if the export fails so a partial one isn't left behind
+func writeExport(path string, export func(w *bufio.Writer) error) (err error) {
+	file, err := os.Create(path)
+	if err != nil {
+		err = fmt.Errorf("could not create %v: %w", path, err)
+		return
+	}
+
+	w := bufio.NewWriter(file)
+	err = export(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	err = errors.Join(err, file.Close())
+
+	if err != nil {
+		_ = os.Remove(path)
+	}
+	return
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"log/slog"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/joshjennings98/backend-demo/server/v2/server"
+)
+
+func init() {
+	rootCmd.AddCommand(replayCmd)
+}
+
+var replayCmd = &cobra.Command{
+	Use:   "replay <session file>",
+	Short: "Play back a recorded presentation",
+	Long:  "Play back a presentation recorded with --record-session in the browser, with the slides and terminal output as they were presented",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+		s, err := server.NewReplayServer(logger, port, args[0], serverOptions(cmd)...)
+		if err != nil {
+			return
+		}
+
+		ctx, stop := signalContext()
+		defer stop()
+
+		err = s.Start(ctx)
+		return
+	},
+}
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -13,16 +15,95 @@ import (
 )
 
 var (
-	commandFile string
-	port        int
+	commandFile    string
+	port           int
+	address        string
+	socket         string
+	allowedOrigins []string
+	auth           bool
+	tlsCert        string
+	tlsKey         string
+	tlsAuto        bool
+	tlsHosts       []string
+	safe           bool
+	iframeHosts    []string
+	recordDir      string
+	recordSession  string
+	transcript     string
 )
 
 func init() {
 	rootCmd.PersistentFlags().StringVarP(&commandFile, "command", "c", "", "Command file to use the presentation")
 	rootCmd.PersistentFlags().IntVarP(&port, "port", "p", 8080, "Port to run server on")
 
+	rootCmd.PersistentFlags().StringVarP(&address, "address", "a", "localhost", "Address to bind the server to")
+	rootCmd.PersistentFlags().StringVar(&socket, "socket", "", "Unix domain socket to listen on instead of a port")
+	rootCmd.PersistentFlags().StringSliceVar(&allowedOrigins, "allowed-origin", nil, "Origin other than localhost that can connect to the websocket, can be repeated")
+	rootCmd.PersistentFlags().BoolVar(&auth, "auth", false, "Require the presenter link printed at startup to run commands, everyone else can only view")
+	rootCmd.PersistentFlags().StringVar(&tlsCert, "tls-cert", "", "Certificate file to serve the presentation over HTTPS")
+	rootCmd.PersistentFlags().StringVar(&tlsKey, "tls-key", "", "Private key file for the certificate")
+	rootCmd.PersistentFlags().BoolVar(&tlsAuto, "tls-auto", false, "Serve the presentation over HTTPS with a generated self-signed certificate")
+	rootCmd.PersistentFlags().StringSliceVar(&tlsHosts, "tls-host", nil, "Hostname or IP address to include in the self-signed certificate, can be repeated")
+
+	rootCmd.PersistentFlags().StringVar(&recordDir, "record", "", "Directory to record every command to as asciicast files")
+	rootCmd.PersistentFlags().StringVar(&recordSession, "record-session", "", "File to record the whole presentation to so it can be replayed")
+	rootCmd.PersistentFlags().StringVar(&transcript, "transcript", "", "File to log every command that is run, and who ran it, to")
+
+	rootCmd.PersistentFlags().BoolVar(&safe, "safe", false, "Preview a presentation from elsewhere with sanitized HTML and without running any commands")
+	rootCmd.PersistentFlags().StringSliceVar(&iframeHosts, "safe-iframe-host", nil, "Host that iframes can be loaded from in safe mode, can be repeated")
+
 	_ = viper.BindPFlag("command", rootCmd.PersistentFlags().Lookup("command"))
 	_ = viper.BindPFlag("port", rootCmd.PersistentFlags().Lookup("port"))
+	_ = viper.BindPFlag("address", rootCmd.PersistentFlags().Lookup("address"))
+	_ = viper.BindPFlag("socket", rootCmd.PersistentFlags().Lookup("socket"))
+	_ = viper.BindPFlag("allowed-origin", rootCmd.PersistentFlags().Lookup("allowed-origin"))
+	_ = viper.BindPFlag("auth", rootCmd.PersistentFlags().Lookup("auth"))
+	_ = viper.BindPFlag("tls-cert", rootCmd.PersistentFlags().Lookup("tls-cert"))
+	_ = viper.BindPFlag("tls-key", rootCmd.PersistentFlags().Lookup("tls-key"))
+	_ = viper.BindPFlag("tls-auto", rootCmd.PersistentFlags().Lookup("tls-auto"))
+	_ = viper.BindPFlag("tls-host", rootCmd.PersistentFlags().Lookup("tls-host"))
+	_ = viper.BindPFlag("record", rootCmd.PersistentFlags().Lookup("record"))
+	_ = viper.BindPFlag("record-session", rootCmd.PersistentFlags().Lookup("record-session"))
+	_ = viper.BindPFlag("transcript", rootCmd.PersistentFlags().Lookup("transcript"))
+	_ = viper.BindPFlag("safe", rootCmd.PersistentFlags().Lookup("safe"))
+	_ = viper.BindPFlag("safe-iframe-host", rootCmd.PersistentFlags().Lookup("safe-iframe-host"))
+}
+
+// serverOptions returns the options for the flags that were set explicitly so they override the front matter
+func serverOptions(cmd *cobra.Command) (opts []server.Option) {
+	flags := cmd.Flags()
+	if flags.Changed("address") {
+		opts = append(opts, server.WithListenAddress(address))
+	}
+	if flags.Changed("socket") {
+		opts = append(opts, server.WithSocket(socket))
+	}
+	if flags.Changed("allowed-origin") {
+		opts = append(opts, server.WithAllowedOrigins(allowedOrigins))
+	}
+	if flags.Changed("auth") {
+		opts = append(opts, server.WithAuth(auth))
+	}
+	if flags.Changed("tls-cert") || flags.Changed("tls-key") {
+		opts = append(opts, server.WithTLSCertificate(tlsCert, tlsKey))
+	}
+	if flags.Changed("tls-auto") || flags.Changed("tls-host") {
+		opts = append(opts, server.WithAutoTLS(tlsHosts))
+	}
+	if flags.Changed("record") {
+		opts = append(opts, server.WithRecordings(recordDir))
+	}
+	if flags.Changed("record-session") {
+		opts = append(opts, server.WithSessionRecording(recordSession))
+	}
+	if flags.Changed("transcript") {
+		opts = append(opts, server.WithTranscript(transcript))
+	}
+	if safe {
+		opts = append(opts, server.WithSafeMode(iframeHosts))
+	}
+
+	return
 }
 
 var rootCmd = &cobra.Command{
@@ -35,18 +116,57 @@ var rootCmd = &cobra.Command{
 			return
 		}
 
+		// the port can also be set in the front matter of the commands file so only override it when set explicitly
+		if !cmd.Flags().Changed("port") {
+			port = 0
+		}
+
 		logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 
-		s, err := server.NewServer(logger, port, commandFile)
+		opts := serverOptions(cmd)
+		s, err := server.NewServer(logger, port, commandFile, opts...)
 		if err != nil {
 			return
 		}
 
-		err = s.Start(context.Background())
+		// nothing runs in safe mode so there is no need to ask whether it can
+		if !safe {
+			var trusted bool
+			if trusted, err = checkTrust(cmd, s); err != nil {
+				return
+			}
+
+			// untrusted presentations can still be shown, just without running anything
+			if !trusted {
+				logger.Warn("presenting with execution disabled since the commands file isn't allowed", "file", commandFile)
+				s, err = server.NewServer(logger, port, commandFile, append(opts, server.WithExecutionDisabled())...)
+				if err != nil {
+					return
+				}
+			}
+		}
+
+		// stop the presentation, and any commands it is running, on ctrl-c
+		ctx, stop := signalContext()
+		defer stop()
+
+		err = s.Start(ctx)
 		return
 	},
 }
 
+// signalContext is cancelled on ctrl-c or SIGTERM. The signals are only caught until then, so that another ctrl-c exits
+// straight away if shutting down hangs, e.g. on a teardown command.
+func signalContext() (ctx context.Context, stop context.CancelFunc) {
+	ctx, stop = signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	return
+}
+
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
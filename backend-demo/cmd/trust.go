@@ -0,0 +1,239 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/joshjennings98/backend-demo/server/v2/server"
+	"github.com/joshjennings98/backend-demo/server/v2/trust"
+	"github.com/joshjennings98/backend-demo/server/v2/types"
+)
+
+func init() {
+	rootCmd.AddCommand(allowCmd, denyCmd)
+}
+
+var allowCmd = &cobra.Command{
+	Use:   "allow [commands file]",
+	Short: "Allow a presentation to run its commands",
+	Long:  "Allow the current contents of a presentation to run its commands without asking, it has to be allowed again if it changes",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		store, file, err := openTrustStore(args)
+		if err != nil {
+			return
+		}
+
+		// the presentation is loaded to find the other files that change what it runs
+		s, err := server.NewServer(slog.New(slog.NewTextHandler(cmd.ErrOrStderr(), nil)), 0, file, server.WithExecutionDisabled())
+		if err != nil {
+			return
+		}
+
+		if err = store.Allow(file, presentationHash(s)); err != nil {
+			return
+		}
+
+		_, _ = fmt.Fprintf(cmd.OutOrStdout(), "%v is allowed to run commands\n", file)
+		return
+	},
+}
+
+var denyCmd = &cobra.Command{
+	Use:   "deny [commands file]",
+	Short: "Stop a presentation from running its commands",
+	Long:  "Stop a presentation from running its commands, it is presented with execution disabled until it is allowed again",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		store, file, err := openTrustStore(args)
+		if err != nil {
+			return
+		}
+
+		if err = store.Deny(file); err != nil {
+			return
+		}
+
+		_, _ = fmt.Fprintf(cmd.OutOrStdout(), "%v is denied from running commands\n", file)
+		return
+	},
+}
+
+// openTrustStore opens the user's trust store and returns the commands file from the arguments, or the -c flag
+func openTrustStore(args []string) (store *trust.Store, file string, err error) {
+	file = commandFile
+	if len(args) > 0 {
+		file = args[0]
+	}
+	if file == "" {
+		err = errors.New("commands file must be provided as an argument or via -c/-commands")
+		return
+	}
+
+	path, err := trust.DefaultPath()
+	if err != nil {
+		return
+	}
+
+	store, err = trust.Open(path)
+	return
+}
+
+// presentationHash is the hash of the presentation as it was loaded, so what is allowed is exactly what was shown
+func presentationHash(s server.IPresentationServer) string {
+	sources := s.GetSources()
+	return trust.Hash(sources[0], sources[1:]...)
+}
+
+// presentationCommands lists every command the presentation can run, grouped by where they come from and what
+// they run with
+func presentationCommands(s server.IPresentationServer) (groups []string, commands [][]string) {
+	settings := s.GetSettings()
+	shell := server.Interpreter(settings, types.Slide{})
+	if len(settings.Setup) > 0 {
+		groups = append(groups, fmt.Sprintf("setup, run with %v", shell))
+		commands = append(commands, settings.Setup)
+	}
+
+	for i := range s.GetSlideCount() {
+		slide, err := s.GetSlide(i)
+		if err != nil || len(slide.ExecuteContent) == 0 {
+			continue
+		}
+
+		groups = append(groups, fmt.Sprintf("slide %v, run with %v", i+1, server.Interpreter(settings, slide)))
+		commands = append(commands, slide.ExecuteContent)
+	}
+
+	if len(settings.Teardown) > 0 {
+		groups = append(groups, fmt.Sprintf("teardown, run with %v", shell))
+		commands = append(commands, settings.Teardown)
+	}
+
+	return
+}
+
+// presentationSettings describes the settings that let others reach the commands or that write files. They are allowed
+// along with the commands, since they are set in the front matter of the same file.
+func presentationSettings(settings types.Settings) (lines []string) {
+	if address := settings.Listen.Address; address != "" && address != "localhost" {
+		lines = append(lines, fmt.Sprintf("listens on %v", address))
+	}
+	if settings.Listen.Socket != "" {
+		lines = append(lines, fmt.Sprintf("listens on the socket %v", settings.Listen.Socket))
+	}
+	if len(settings.Listen.AllowedOrigins) > 0 {
+		lines = append(lines, fmt.Sprintf("accepts terminal connections from %v", strings.Join(settings.Listen.AllowedOrigins, ", ")))
+	}
+	if len(lines) > 0 && !settings.Auth {
+		lines = append(lines, "lets anyone who can reach it run the commands, since auth is off")
+	}
+
+	if settings.Transcript.File != "" {
+		lines = append(lines, fmt.Sprintf("writes a transcript to %v", settings.Transcript.File))
+	}
+	if settings.Record.Dir != "" {
+		lines = append(lines, fmt.Sprintf("writes recordings to %v", settings.Record.Dir))
+	}
+	if settings.Record.Session != "" {
+		lines = append(lines, fmt.Sprintf("writes the session to %v", settings.Record.Session))
+	}
+	if settings.Fallback.Record {
+		lines = append(lines, fmt.Sprintf("writes the output of commands to %v", settings.Fallback.Dir))
+	}
+
+	return
+}
+
+// checkTrust asks whether an unfamiliar presentation can run its commands, returning false if it should be presented with execution disabled
+func checkTrust(cmd *cobra.Command, s server.IPresentationServer) (trusted bool, err error) {
+	groups, commands := presentationCommands(s)
+	settings := presentationSettings(s.GetSettings())
+	if len(groups) == 0 && len(settings) == 0 {
+		trusted = true
+		return
+	}
+
+	store, file, err := openTrustStore(nil)
+	if err != nil {
+		return
+	}
+
+	hash := presentationHash(s)
+	status, err := store.Check(file, hash)
+	if err != nil {
+		return
+	}
+
+	switch status {
+	case trust.StatusAllowed:
+		trusted = true
+		return
+	case trust.StatusDenied:
+		return
+	}
+
+	out := cmd.ErrOrStderr()
+	_, _ = fmt.Fprintf(out, "%v is new or has changed since it was last allowed.\n", file)
+	if len(settings) > 0 {
+		_, _ = fmt.Fprintln(out, "\n  The presentation:")
+		for _, setting := range settings {
+			_, _ = fmt.Fprintf(out, "    %v\n", setting)
+		}
+	}
+	if envFile := s.GetSettings().EnvFile; envFile != "" {
+		_, _ = fmt.Fprintf(out, "\n  Its commands run with the environment variables in %v\n", envFile)
+	}
+	for i, group := range groups {
+		_, _ = fmt.Fprintf(out, "\n  %v:\n", group)
+		for _, command := range commands[i] {
+			_, _ = fmt.Fprintf(out, "    %v\n", command)
+		}
+	}
+
+	if !isInteractive(cmd.InOrStdin()) {
+		_, _ = fmt.Fprintf(out, "\nRun 'backend-demo allow %v' to allow them.\n", file)
+		return
+	}
+
+	_, _ = fmt.Fprint(out, "\nAllow this presentation to run? [y/N] ")
+	answer, err := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+	if errors.Is(err, io.EOF) {
+		// nothing was typed so finish the prompt's line before anything else is printed
+		_, _ = fmt.Fprintln(out)
+		err = nil
+	}
+	if err != nil {
+		return
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer != "y" && answer != "yes" {
+		return
+	}
+
+	if err = store.Allow(file, hash); err != nil {
+		return
+	}
+
+	trusted = true
+	return
+}
+
+// isInteractive reports whether the input is a terminal that someone can answer the prompt from
+func isInteractive(in io.Reader) bool {
+	f, ok := in.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -10,9 +10,12 @@
 package mocks
 
 import (
+	context "context"
+	io "io"
 	reflect "reflect"
 
 	websocket "github.com/gorilla/websocket"
+	types "github.com/joshjennings98/backend-demo/server/v2/types"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -39,6 +42,20 @@ func (m *MockICommandManager) EXPECT() *MockICommandManagerMockRecorder {
 	return m.recorder
 }
 
+// CheckInterpreters mocks base method.
+func (m *MockICommandManager) CheckInterpreters(arg0 []types.Slide) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckInterpreters", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckInterpreters indicates an expected call of CheckInterpreters.
+func (mr *MockICommandManagerMockRecorder) CheckInterpreters(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckInterpreters", reflect.TypeOf((*MockICommandManager)(nil).CheckInterpreters), arg0)
+}
+
 // Clear mocks base method.
 func (m *MockICommandManager) Clear() error {
 	m.ctrl.T.Helper()
@@ -67,6 +84,35 @@ func (mr *MockICommandManagerMockRecorder) CloseWebsocketConnection() *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CloseWebsocketConnection", reflect.TypeOf((*MockICommandManager)(nil).CloseWebsocketConnection))
 }
 
+// ControlPlayback mocks base method.
+func (m *MockICommandManager) ControlPlayback(arg0 string) (types.PlaybackState, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ControlPlayback", arg0)
+	ret0, _ := ret[0].(types.PlaybackState)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ControlPlayback indicates an expected call of ControlPlayback.
+func (mr *MockICommandManagerMockRecorder) ControlPlayback(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ControlPlayback", reflect.TypeOf((*MockICommandManager)(nil).ControlPlayback), arg0)
+}
+
+// Exec mocks base method.
+func (m *MockICommandManager) Exec(arg0 context.Context, arg1 []string, arg2 io.Writer) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Exec", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Exec indicates an expected call of Exec.
+func (mr *MockICommandManagerMockRecorder) Exec(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Exec", reflect.TypeOf((*MockICommandManager)(nil).Exec), arg0, arg1, arg2)
+}
+
 // IsRunning mocks base method.
 func (m *MockICommandManager) IsRunning() bool {
 	m.ctrl.T.Helper()
@@ -95,18 +141,86 @@ func (mr *MockICommandManagerMockRecorder) IsWebsocketConnected() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsWebsocketConnected", reflect.TypeOf((*MockICommandManager)(nil).IsWebsocketConnected))
 }
 
+// LastOutput mocks base method.
+func (m *MockICommandManager) LastOutput(arg0 int) string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LastOutput", arg0)
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// LastOutput indicates an expected call of LastOutput.
+func (mr *MockICommandManagerMockRecorder) LastOutput(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LastOutput", reflect.TypeOf((*MockICommandManager)(nil).LastOutput), arg0)
+}
+
+// NextStep mocks base method.
+func (m *MockICommandManager) NextStep(arg0 int) int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NextStep", arg0)
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// NextStep indicates an expected call of NextStep.
+func (mr *MockICommandManagerMockRecorder) NextStep(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NextStep", reflect.TypeOf((*MockICommandManager)(nil).NextStep), arg0)
+}
+
+// Replay mocks base method.
+func (m *MockICommandManager) Replay(arg0 types.Slide) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Replay", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Replay indicates an expected call of Replay.
+func (mr *MockICommandManagerMockRecorder) Replay(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Replay", reflect.TypeOf((*MockICommandManager)(nil).Replay), arg0)
+}
+
+// Result mocks base method.
+func (m *MockICommandManager) Result(arg0 int) types.CommandResult {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Result", arg0)
+	ret0, _ := ret[0].(types.CommandResult)
+	return ret0
+}
+
+// Result indicates an expected call of Result.
+func (mr *MockICommandManagerMockRecorder) Result(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Result", reflect.TypeOf((*MockICommandManager)(nil).Result), arg0)
+}
+
 // Run mocks base method.
-func (m *MockICommandManager) Run(arg0 []string) error {
+func (m *MockICommandManager) Run(arg0 types.Slide, arg1 string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Run", arg0)
+	ret := m.ctrl.Call(m, "Run", arg0, arg1)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Run indicates an expected call of Run.
-func (mr *MockICommandManagerMockRecorder) Run(arg0 any) *gomock.Call {
+func (mr *MockICommandManagerMockRecorder) Run(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Run", reflect.TypeOf((*MockICommandManager)(nil).Run), arg0, arg1)
+}
+
+// SetTerminalRecorder mocks base method.
+func (m *MockICommandManager) SetTerminalRecorder(arg0 io.Writer) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetTerminalRecorder", arg0)
+}
+
+// SetTerminalRecorder indicates an expected call of SetTerminalRecorder.
+func (mr *MockICommandManagerMockRecorder) SetTerminalRecorder(arg0 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Run", reflect.TypeOf((*MockICommandManager)(nil).Run), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTerminalRecorder", reflect.TypeOf((*MockICommandManager)(nil).SetTerminalRecorder), arg0)
 }
 
 // SetWebsocketConnection mocks base method.
@@ -121,6 +235,20 @@ func (mr *MockICommandManagerMockRecorder) SetWebsocketConnection(arg0 any) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetWebsocketConnection", reflect.TypeOf((*MockICommandManager)(nil).SetWebsocketConnection), arg0)
 }
 
+// Shutdown mocks base method.
+func (m *MockICommandManager) Shutdown(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Shutdown", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Shutdown indicates an expected call of Shutdown.
+func (mr *MockICommandManagerMockRecorder) Shutdown(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Shutdown", reflect.TypeOf((*MockICommandManager)(nil).Shutdown), arg0)
+}
+
 // Stop mocks base method.
 func (m *MockICommandManager) Stop() error {
 	m.ctrl.T.Helper()
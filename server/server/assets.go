@@ -0,0 +1,171 @@
+package server
+
+import (
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"github.com/joshjennings98/backend-demo/server/v2/types"
+)
+
+// assetAttributes are the attributes of each element that embed a file in a slide
+var assetAttributes = map[string][]string{
+	"img":    {"src", "srcset"},
+	"video":  {"src", "poster"},
+	"audio":  {"src"},
+	"source": {"src", "srcset"},
+	"track":  {"src"},
+	"iframe": {"src"},
+	"embed":  {"src"},
+	"object": {"data"},
+}
+
+// localAssetPath returns the path of a referenced file relative to the presentation directory,
+// or false if the reference is to another site or a data URI.
+func localAssetPath(ref string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" {
+		return "", false
+	}
+
+	// resolve the path the same way the browser does against /presentation
+	name := strings.TrimPrefix(path.Clean("/"+u.Path), "/")
+	return name, name != ""
+}
+
+// collectAssets finds the local files embedded in the rendered HTML of a slide
+func collectAssets(content string) (assets []string) {
+	tokenizer := html.NewTokenizer(strings.NewReader(content))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			for _, attr := range token.Attr {
+				if !slices.Contains(assetAttributes[token.Data], attr.Key) {
+					continue
+				}
+
+				refs := []string{attr.Val}
+				if attr.Key == "srcset" {
+					refs = nil
+					for candidate := range strings.SplitSeq(attr.Val, ",") {
+						if fields := strings.Fields(candidate); len(fields) > 0 {
+							refs = append(refs, fields[0])
+						}
+					}
+				}
+
+				for _, ref := range refs {
+					if name, ok := localAssetPath(ref); ok && !slices.Contains(assets, name) {
+						assets = append(assets, name)
+					}
+				}
+			}
+		}
+	}
+}
+
+// embedElements are the elements that load and play something inside a slide rather than showing a picture
+var embedElements = []string{"iframe", "video", "audio", "embed", "object"}
+
+// embedPlaceholders replaces the embedded pages, videos and other media in the HTML of a slide with a placeholder
+// naming what they are, so that a preview of the slide doesn't load or play them
+func embedPlaceholders(content string) string {
+	var b strings.Builder
+	tokenizer := html.NewTokenizer(strings.NewReader(content))
+	skipping, depth := "", 0
+	for {
+		kind := tokenizer.Next()
+		if kind == html.ErrorToken {
+			return b.String()
+		}
+
+		name, _ := tokenizer.TagName()
+		tag := string(name)
+		switch {
+		case skipping != "":
+			// the fallback content and sources of the element are dropped along with it
+			if tag == skipping && kind == html.StartTagToken {
+				depth++
+			} else if tag == skipping && kind == html.EndTagToken {
+				if depth--; depth == 0 {
+					skipping = ""
+				}
+			}
+		case (kind == html.StartTagToken || kind == html.SelfClosingTagToken) && slices.Contains(embedElements, tag):
+			b.WriteString(`<span class="embed-placeholder">` + tag + `</span>`)
+			if kind == html.StartTagToken && tag != "embed" {
+				skipping, depth = tag, 1
+			}
+		default:
+			b.Write(tokenizer.Raw())
+		}
+	}
+}
+
+func isHidden(name string) bool {
+	return slices.ContainsFunc(strings.Split(name, "/"), func(segment string) bool {
+		return strings.HasPrefix(segment, ".")
+	})
+}
+
+// isServableAsset checks whether a file in the presentation directory can be served.
+// Only files embedded in slides, files in the assets directory, files matching the allowlist and the theme are served.
+// Hidden files and the commands file are never served.
+func (s *server) isServableAsset(name string) bool {
+	if name == "" || isHidden(name) || name == filepath.Base(s.commandsFile) {
+		return false
+	}
+
+	if strings.HasSuffix(s.settings.Theme, ".css") && name == path.Clean(s.settings.Theme) {
+		return true
+	}
+
+	if dir := path.Clean(filepath.ToSlash(s.settings.Assets.Dir)); s.settings.Assets.Dir != "" && strings.HasPrefix(name, dir+"/") {
+		return true
+	}
+
+	for _, pattern := range s.settings.Assets.Allow {
+		if ok, _ := path.Match(path.Clean(filepath.ToSlash(pattern)), name); ok {
+			return true
+		}
+	}
+
+	return slices.ContainsFunc(s.slides, func(slide types.Slide) bool {
+		return slices.Contains(slide.Assets, name)
+	})
+}
+
+func (s *server) HandlerAsset(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if !s.isServableAsset(name) {
+		http.NotFound(w, r)
+		s.logger.Warn("refused to serve file that isn't a presentation asset", "path", name)
+		return
+	}
+
+	// the root stops symlinks from escaping the presentation directory
+	root, err := os.OpenRoot(filepath.Dir(s.commandsFile))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		s.logger.Error("could not open presentation directory", "error", err.Error())
+		return
+	}
+	defer root.Close()
+
+	if info, err := fs.Stat(root.FS(), name); err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	http.ServeFileFS(w, r, root.FS(), name)
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectAssets(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		assets  []string
+	}{
+		{
+			name:    "image",
+			content: `<p><img src="images/logo.png" alt="logo" /></p>`,
+			assets:  []string{"images/logo.png"},
+		},
+		{
+			name:    "remote and data URIs are ignored",
+			content: `<img src="https://example.com/a.png"><img src="//cdn.example.com/b.png"><img src="data:image/png;base64,AAAA">`,
+		},
+		{
+			name:    "video with poster and sources",
+			content: `<video src="./demo.mp4" poster="poster.png"><source src="demo.webm"><track src="subs.vtt"></video>`,
+			assets:  []string{"demo.mp4", "poster.png", "demo.webm", "subs.vtt"},
+		},
+		{
+			name:    "srcset",
+			content: `<img srcset="small.png 1x, large.png 2x">`,
+			assets:  []string{"small.png", "large.png"},
+		},
+		{
+			name:    "paths are resolved against the presentation directory",
+			content: `<iframe src="/docs/../docs/page.html?x=1#top"></iframe><img src="../outside.png">`,
+			assets:  []string{"docs/page.html", "outside.png"},
+		},
+		{
+			name:    "links are not assets",
+			content: `<a href="notes.pdf">notes</a>`,
+		},
+		{
+			name:    "duplicates",
+			content: `<img src="a.png"><img src="./a.png">`,
+			assets:  []string{"a.png"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.assets, collectAssets(tc.content))
+		})
+	}
+}
+
+func TestHandlerAsset(t *testing.T) {
+	s, err := NewServer(slog.New(slog.NewTextHandler(os.Stdout, nil)), 0, filepath.Join("..", "testdata", "assets.txt"))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		path   string
+		status int
+	}{
+		{path: "/assets/logo.svg", status: http.StatusOK},
+		{path: "/assets/demo.mp4", status: http.StatusOK},
+		{path: "/assets/absolute.png", status: http.StatusNotFound}, // referenced but doesn't exist
+		{path: "/assets/media/clip.webm", status: http.StatusOK},    // in the assets directory
+		{path: "/assets/theme.css", status: http.StatusOK},          // in the allowlist
+		{path: "/assets/secret.txt", status: http.StatusNotFound},   // only mentioned in a command
+		{path: "/assets/.env", status: http.StatusNotFound},
+		{path: "/assets/media/.hidden", status: http.StatusNotFound},
+		{path: "/assets.txt", status: http.StatusNotFound},
+		{path: "/assets/media", status: http.StatusNotFound},
+		{path: "/assets/../assets/logo.svg", status: http.StatusBadRequest}, // the mux redirects these to the clean path
+		{path: "/server_test.go", status: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			s.HandlerAsset(rr, httptest.NewRequest("GET", tc.path, nil))
+			assert.Equal(t, tc.status, rr.Code)
+		})
+	}
+
+	rr := httptest.NewRecorder()
+	s.HandlerAsset(rr, httptest.NewRequest("GET", "/assets/logo.svg", nil))
+	assert.Contains(t, rr.Body.String(), "<svg")
+}
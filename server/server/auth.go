@@ -0,0 +1,168 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	sessionCookieName = "backend-demo-session"
+	csrfFieldName     = "csrf_token"
+	csrfHeaderName    = "X-CSRF-Token"
+	// websocketProtocol is the subprotocol of the terminal websocket, the presenter's browser offers its CSRF token
+	// alongside it since the browser can't set headers on a websocket
+	websocketProtocol = "backend-demo"
+	tokenBytes        = 32
+)
+
+// access describes what the client a page is rendered for is allowed to do
+type access struct {
+	canExecute bool
+	csrfToken  string
+	// offline is set for an exported copy of the presentation, which plays recorded output rather than running
+	// commands since there isn't a server
+	offline bool
+}
+
+// authenticator lets the presenter exchange the token printed at startup for a session cookie.
+// Clients without a session can follow the presentation but can't run commands or connect to the terminal.
+type authenticator struct {
+	token        string
+	secureCookie bool
+	sessions     map[string]string // session ID to CSRF token
+	mu           sync.Mutex
+}
+
+func randomToken() (token string, err error) {
+	b := make([]byte, tokenBytes)
+	if _, err = rand.Read(b); err != nil {
+		err = fmt.Errorf("could not generate random token: %w", err)
+		return
+	}
+
+	token = hex.EncodeToString(b)
+	return
+}
+
+func newAuthenticator(secureCookie bool) (a *authenticator, err error) {
+	token, err := randomToken()
+	if err != nil {
+		return
+	}
+
+	a = &authenticator{
+		token:        token,
+		secureCookie: secureCookie,
+		sessions:     map[string]string{},
+	}
+	return
+}
+
+// login starts a presenter session if the request has the token in its query, returning whether it did
+func (a *authenticator) login(w http.ResponseWriter, r *http.Request) (ok bool, err error) {
+	token := r.URL.Query().Get("token")
+	if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+		return
+	}
+
+	sessionID, err := randomToken()
+	if err != nil {
+		return
+	}
+
+	csrfToken, err := randomToken()
+	if err != nil {
+		return
+	}
+
+	a.mu.Lock()
+	a.sessions[sessionID] = csrfToken
+	a.mu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    sessionID,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   a.secureCookie,
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	ok = true
+	return
+}
+
+// csrfToken returns the CSRF token of the presenter session the request belongs to
+func (a *authenticator) csrfToken(r *http.Request) (token string, ok bool) {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	token, ok = a.sessions[cookie.Value]
+	return
+}
+
+// checkCSRF reports whether the request belongs to a presenter session and carries its CSRF token in a form field,
+// header or, for websocket upgrades, the subprotocols. It is never read from the URL, which ends up in logs.
+func (a *authenticator) checkCSRF(r *http.Request) bool {
+	expected, ok := a.csrfToken(r)
+	if !ok {
+		return false
+	}
+
+	token := r.Header.Get(csrfHeaderName)
+	if token == "" {
+		token = r.PostFormValue(csrfFieldName)
+	}
+	if token == "" && websocket.IsWebSocketUpgrade(r) {
+		for _, protocol := range websocket.Subprotocols(r) {
+			if protocol != websocketProtocol {
+				token = protocol
+				break
+			}
+		}
+	}
+
+	return subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
+// access returns what the client making the request is allowed to do
+func (s *server) access(r *http.Request) access {
+	if s.settings.ExecutionDisabled {
+		return access{}
+	}
+
+	if s.auth == nil {
+		return access{canExecute: true}
+	}
+
+	token, ok := s.auth.csrfToken(r)
+	return access{canExecute: ok, csrfToken: token}
+}
+
+// authorize checks that a request to run or stop commands comes from the presenter, responding with forbidden if it doesn't
+func (s *server) authorize(w http.ResponseWriter, r *http.Request) bool {
+	if s.settings.ExecutionDisabled {
+		w.WriteHeader(http.StatusForbidden)
+		s.logger.Warn("rejected request since execution is disabled", "path", r.URL.Path, "remote", r.RemoteAddr)
+		return false
+	}
+
+	if s.auth == nil || s.auth.checkCSRF(r) {
+		return true
+	}
+
+	w.WriteHeader(http.StatusForbidden)
+	s.logger.Warn("rejected request without a presenter session", "path", r.URL.Path, "remote", r.RemoteAddr)
+	return false
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func login(t *testing.T, a *authenticator) (cookie *http.Cookie, csrfToken string) {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	ok, err := a.login(rr, httptest.NewRequest("GET", "/presentation?token="+a.token, nil))
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	cookies := rr.Result().Cookies() //nolint:bodyclose // recorder bodies don't need closing
+	require.Len(t, cookies, 1)
+	cookie = cookies[0]
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(cookie)
+	csrfToken, ok = a.csrfToken(req)
+	require.True(t, ok)
+	return
+}
+
+func TestAuthenticator_Login(t *testing.T) {
+	a, err := newAuthenticator(true)
+	require.NoError(t, err)
+	assert.Len(t, a.token, 2*tokenBytes)
+
+	t.Run("valid token", func(t *testing.T) {
+		cookie, csrfToken := login(t, a)
+		assert.Equal(t, sessionCookieName, cookie.Name)
+		assert.True(t, cookie.HttpOnly)
+		assert.True(t, cookie.Secure)
+		assert.Equal(t, http.SameSiteStrictMode, cookie.SameSite)
+		assert.NotEmpty(t, csrfToken)
+		assert.NotEqual(t, a.token, cookie.Value)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		ok, err := a.login(rr, httptest.NewRequest("GET", "/presentation?token=guess", nil))
+		require.NoError(t, err)
+		assert.False(t, ok)
+		assert.Empty(t, rr.Result().Cookies()) //nolint:bodyclose // recorder bodies don't need closing
+	})
+
+	t.Run("no token", func(t *testing.T) {
+		ok, err := a.login(httptest.NewRecorder(), httptest.NewRequest("GET", "/presentation", nil))
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+}
+
+func TestAuthenticator_CheckCSRF(t *testing.T) {
+	a, err := newAuthenticator(false)
+	require.NoError(t, err)
+	cookie, csrfToken := login(t, a)
+
+	form := func(token string) *http.Request {
+		req := httptest.NewRequest("POST", "/commands/1/start", strings.NewReader(url.Values{csrfFieldName: {token}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req
+	}
+
+	req := form(csrfToken)
+	req.AddCookie(cookie)
+	assert.True(t, a.checkCSRF(req), "session and form token")
+
+	websocketRequest := func(protocols string) *http.Request {
+		req := httptest.NewRequest("GET", "/ws", nil)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Protocol", protocols)
+		req.AddCookie(cookie)
+		return req
+	}
+	assert.True(t, a.checkCSRF(websocketRequest(websocketProtocol+", "+csrfToken)), "session and subprotocol token")
+	assert.False(t, a.checkCSRF(websocketRequest(websocketProtocol)), "websocket without a token")
+
+	req = httptest.NewRequest("GET", "/ws?csrf_token="+csrfToken, nil)
+	req.AddCookie(cookie)
+	assert.False(t, a.checkCSRF(req), "query token")
+
+	req = httptest.NewRequest("POST", "/commands/1/start", nil)
+	req.Header.Set(csrfHeaderName, csrfToken)
+	req.AddCookie(cookie)
+	assert.True(t, a.checkCSRF(req), "session and header token")
+
+	req = form("wrong")
+	req.AddCookie(cookie)
+	assert.False(t, a.checkCSRF(req), "wrong token")
+
+	req = form("")
+	req.AddCookie(cookie)
+	assert.False(t, a.checkCSRF(req), "missing token")
+
+	assert.False(t, a.checkCSRF(form(csrfToken)), "missing session")
+
+	req = form(csrfToken)
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: "forged"})
+	assert.False(t, a.checkCSRF(req), "unknown session")
+}
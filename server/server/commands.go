@@ -2,34 +2,70 @@ package server
 
 import (
 	"bytes"
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"net/http"
+	"net/url"
+	"os"
 	"os/exec"
+	"regexp"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/websocket"
+
+	"github.com/joshjennings98/backend-demo/server/v2/types"
 )
 
 const (
 	terminalBufferSize = 1024
+	defaultShell       = "sh"
+	stopTimeout        = 5 * time.Second
+	outputTailSize     = 4096
+	outputPreviewLines = 6
 )
 
-func newUpgrader(isTest bool) websocket.Upgrader {
+// defaultInheritEnv are the variables kept in a clean environment when the presentation doesn't list any
+var defaultInheritEnv = []string{"PATH", "HOME"}
+
+var ansiEscapeRegex = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\a\x1b]*(?:\a|\x1b\\)|\x1b[()][A-Za-z0-9]|\r`)
+
+// isAllowedOrigin only allows websocket connections from pages served on localhost unless other origins are allowed explicitly.
+// Same origin requests aren't allowed by default to prevent DNS rebinding attacks against a server bound to localhost.
+func isAllowedOrigin(origin string, allowedOrigins []string) bool {
+	for _, allowed := range allowedOrigins {
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Port() == "" {
+		return false
+	}
+
+	return u.Hostname() == "localhost" || u.Hostname() == "127.0.0.1"
+}
+
+func newUpgrader(isTest bool, allowedOrigins []string) websocket.Upgrader {
 	return websocket.Upgrader{
 		ReadBufferSize:  terminalBufferSize,
 		WriteBufferSize: terminalBufferSize,
+		Subprotocols:    []string{websocketProtocol},
 		CheckOrigin: func(r *http.Request) bool {
 			origin := r.Header.Get("Origin")
 			if isTest && origin == "" {
 				return true
 			}
-			return strings.HasPrefix(origin, "http://localhost:") ||
-				strings.HasPrefix(origin, "http://127.0.0.1:")
+			return isAllowedOrigin(origin, allowedOrigins)
 		},
 	}
 }
@@ -39,6 +75,12 @@ type wsWriter struct {
 	ctx  context.Context
 	conn *websocket.Conn
 	mu   *sync.Mutex
+	// raw writes output without adding carriage returns, for output that already has them
+	raw bool
+	// recorder is sent everything that is written to the terminal when the command is being recorded
+	recorder io.Writer
+	// session is sent everything that is written to the terminal when the presentation is being recorded
+	session io.Writer
 }
 
 func newWSWriter(ctx context.Context, ws *websocket.Conn, mu *sync.Mutex) *wsWriter {
@@ -52,7 +94,13 @@ func newWSWriter(ctx context.Context, ws *websocket.Conn, mu *sync.Mutex) *wsWri
 func (w *wsWriter) writeMessage(msg []byte) (err error) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
-	return w.conn.WriteMessage(websocket.TextMessage, msg)
+
+	// the session is written while the connection is locked so its output is in the order it was sent
+	if err = w.conn.WriteMessage(websocket.TextMessage, msg); err == nil && w.session != nil {
+		_, _ = w.session.Write(msg)
+	}
+
+	return
 }
 
 func (w *wsWriter) Write(p []byte) (n int, err error) {
@@ -68,29 +116,159 @@ func (w *wsWriter) Write(p []byte) (n int, err error) {
 		return
 	}
 
-	msg := bytes.ReplaceAll(p, []byte{'\n'}, []byte{'\n', '\r'})
+	msg := p
+	if !w.raw {
+		msg = bytes.ReplaceAll(p, []byte{'\n'}, []byte{'\n', '\r'})
+	}
 	err = w.writeMessage(msg)
 	if err != nil {
 		err = fmt.Errorf("could not write message '%v': %w", string(msg), err)
 		return
 	}
 
+	// a failed recording shouldn't stop the command
+	if w.recorder != nil {
+		_, _ = w.recorder.Write(msg)
+	}
+
+	n = len(p)
+	return
+}
+
+// outputTail keeps the end of a command's output so it can be previewed in the overview
+type outputTail struct {
+	mu  sync.Mutex
+	buf []byte
+	// limitHit describes the limit that stopped the command, if it was stopped by one
+	limitHit string
+	// canReplay and replayed are whether the output of the last successful run can be, or was, replayed instead
+	canReplay bool
+	replayed  bool
+}
+
+func (o *outputTail) Write(p []byte) (n int, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.buf = append(o.buf, p...)
+	if len(o.buf) > outputTailSize {
+		o.buf = bytes.Clone(o.buf[len(o.buf)-outputTailSize:])
+	}
+
+	n = len(p)
+	return
+}
+
+// preview returns the last few lines of output with terminal escape sequences removed
+func (o *outputTail) preview() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	lines := strings.Split(ansiEscapeRegex.ReplaceAllString(string(o.buf), ""), "\n")
+	lines = slices.DeleteFunc(lines, func(line string) bool { return strings.TrimSpace(line) == "" })
+	if len(lines) > outputPreviewLines {
+		lines = lines[len(lines)-outputPreviewLines:]
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// logWriter is an io.Writer that logs each line written to it
+type logWriter struct {
+	logger *slog.Logger
+	name   string
+	buf    []byte
+}
+
+func newLogWriter(logger *slog.Logger, name string) *logWriter {
+	return &logWriter{
+		logger: logger,
+		name:   name,
+	}
+}
+
+func (w *logWriter) Write(p []byte) (n int, err error) {
+	w.buf = append(w.buf, p...)
+	for {
+		line, rest, found := bytes.Cut(w.buf, []byte{'\n'})
+		if !found {
+			break
+		}
+		w.logger.Info("command output", "commands", w.name, "output", string(bytes.TrimRight(line, "\r")))
+		w.buf = rest
+	}
+
 	n = len(p)
 	return
 }
 
+// Flush logs any remaining output that didn't end in a newline
+func (w *logWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.logger.Info("command output", "commands", w.name, "output", string(w.buf))
+		w.buf = nil
+	}
+}
+
 type commandManager struct {
-	cancel  context.CancelFunc
-	running atomic.Bool
-	ws      *websocket.Conn
-	wsMu    sync.Mutex
-	logger  *slog.Logger
+	cancel       context.CancelFunc
+	running      atomic.Bool
+	wg           sync.WaitGroup
+	ws           *websocket.Conn
+	wsMu         sync.Mutex
+	session      io.Writer
+	outputs      map[int]*outputTail
+	outputsMu    sync.Mutex
+	steps        *stepSession
+	stepsMu      sync.Mutex
+	player       *player
+	playerMu     sync.Mutex
+	transcriptMu sync.Mutex
+	settings     types.Settings
+	redactor     *redactor
+	logger       *slog.Logger
 }
 
-func newCommandManager(logger *slog.Logger) ICommandManager {
+func newCommandManager(logger *slog.Logger, settings types.Settings, redactor *redactor) ICommandManager {
 	return &commandManager{
-		logger: logger,
+		outputs:  map[int]*outputTail{},
+		settings: settings,
+		redactor: redactor,
+		logger:   logger,
+	}
+}
+
+func (c *commandManager) LastOutput(id int) string {
+	c.outputsMu.Lock()
+	defer c.outputsMu.Unlock()
+
+	if output, ok := c.outputs[id]; ok {
+		return output.preview()
+	}
+
+	return ""
+}
+
+func (c *commandManager) Result(id int) (result types.CommandResult) {
+	c.outputsMu.Lock()
+	defer c.outputsMu.Unlock()
+
+	if output, ok := c.outputs[id]; ok {
+		output.mu.Lock()
+		defer output.mu.Unlock()
+		result = types.CommandResult{LimitHit: output.limitHit, CanReplay: output.canReplay, Replayed: output.replayed}
 	}
+
+	return
+}
+
+func (c *commandManager) resetOutput(id int) (output *outputTail) {
+	c.outputsMu.Lock()
+	defer c.outputsMu.Unlock()
+
+	output = &outputTail{}
+	c.outputs[id] = output
+	return
 }
 
 func (c *commandManager) IsRunning() bool {
@@ -116,6 +294,12 @@ func (c *commandManager) CloseWebsocketConnection() (err error) {
 	return
 }
 
+func (c *commandManager) SetTerminalRecorder(recorder io.Writer) {
+	c.wsMu.Lock()
+	defer c.wsMu.Unlock()
+	c.session = recorder
+}
+
 func (c *commandManager) IsWebsocketConnected() bool {
 	c.wsMu.Lock()
 	defer c.wsMu.Unlock()
@@ -123,6 +307,10 @@ func (c *commandManager) IsWebsocketConnected() bool {
 }
 
 func (c *commandManager) Stop() (err error) {
+	c.stepsMu.Lock()
+	c.steps = nil
+	c.stepsMu.Unlock()
+
 	if c.cancel != nil {
 		c.logger.Info("cancelling command")
 		c.cancel()
@@ -133,6 +321,36 @@ func (c *commandManager) Stop() (err error) {
 	return
 }
 
+// Shutdown stops any running command, waiting for it to exit until ctx is done,
+// and then closes the websocket connection with a close frame so the browser knows the server is going away.
+func (c *commandManager) Shutdown(ctx context.Context) (err error) {
+	_ = c.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("commands did not stop in time: %w", ctx.Err())
+	}
+
+	c.wsMu.Lock()
+	defer c.wsMu.Unlock()
+
+	if c.ws != nil {
+		msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+		_ = c.ws.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+		err = errors.Join(err, c.ws.Close())
+		c.ws = nil
+	}
+
+	return
+}
+
 func (c *commandManager) Clear() (err error) {
 	c.wsMu.Lock()
 	defer c.wsMu.Unlock()
@@ -141,35 +359,208 @@ func (c *commandManager) Clear() (err error) {
 		return
 	}
 
-	return c.ws.WriteMessage(websocket.TextMessage, []byte("\033[2J\033[H"))
+	msg := []byte("\033[2J\033[H")
+	if err = c.ws.WriteMessage(websocket.TextMessage, msg); err == nil && c.session != nil {
+		_, _ = c.session.Write(msg)
+	}
+
+	return
+}
+
+// terminal creates a writer to the terminal for a command that is stopped when ctx is done
+func (c *commandManager) terminal(ctx context.Context) *wsWriter {
+	c.wsMu.Lock()
+	defer c.wsMu.Unlock()
+
+	terminal := newWSWriter(ctx, c.ws, &c.wsMu)
+	terminal.session = c.session
+	return terminal
 }
 
-func (c *commandManager) run(ctx context.Context, script string) {
+// command creates the process for a slide's commands using the shell, working directory and environment from the
+// settings and the slide
+func (c *commandManager) command(ctx context.Context, slide types.Slide, limits types.Limits) *exec.Cmd {
+	return c.process(ctx, slide, withLimits(interpreterArgs(c.interpreter(slide), strings.Join(slide.ExecuteContent, "\n")), limits))
+}
+
+// process creates a process in the working directory and with the environment for a slide
+func (c *commandManager) process(ctx context.Context, slide types.Slide, args []string) (cmd *exec.Cmd) {
+	cmd = exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = cmp.Or(slide.WorkingDir, c.settings.WorkingDir)
+	cmd.Env = c.environment(slide)
+	setProcessGroup(cmd)
+
+	return
+}
+
+// environment returns the variables for a slide's command, or nil to inherit the server's environment unchanged
+func (c *commandManager) environment(slide types.Slide) (env []string) {
+	if !c.settings.CleanEnv && len(c.settings.Env) == 0 && len(slide.Env) == 0 {
+		return
+	}
+
+	inherited := os.Environ()
+	if c.settings.CleanEnv {
+		allowed := c.settings.InheritEnv
+		if len(allowed) == 0 {
+			allowed = defaultInheritEnv
+		}
+
+		inherited = slices.DeleteFunc(inherited, func(variable string) bool {
+			key, _, _ := strings.Cut(variable, "=")
+			return !slices.Contains(allowed, key)
+		})
+	}
+
+	// later values take precedence so the slide's variables are added last
+	env = inherited
+	for _, vars := range []map[string]string{c.settings.Env, slide.Env} {
+		for _, key := range slices.Sorted(maps.Keys(vars)) {
+			env = append(env, fmt.Sprintf("%v=%v", key, vars[key]))
+		}
+	}
+
+	return
+}
+
+// Exec runs commands in a single shell to completion, writing their output to output rather than the terminal
+func (c *commandManager) Exec(ctx context.Context, commands []string, output io.Writer) error {
+	cmd := c.command(ctx, types.Slide{ExecuteContent: commands}, types.Limits{})
+
+	redacted := c.redactor.Writer(output)
+	cmd.Stdout = redacted
+	cmd.Stderr = redacted
+
+	defer killProcessGroup(cmd)
+	err := cmd.Run()
+	return errors.Join(err, redacted.Close())
+}
+
+func (c *commandManager) run(ctx context.Context, slide types.Slide, client string, limits types.Limits, output *outputTail) {
 	c.running.Store(true)
 	defer func() {
 		c.running.Store(false)
+		c.wg.Done()
 	}()
 
-	c.logger.Info("executing commands", "script", script)
+	c.logger.Info("executing commands", "script", strings.Join(slide.ExecuteContent, "\n"))
 
-	cmd := exec.CommandContext(ctx, "sh", "-c", script)
+	terminal := c.terminal(ctx)
+	var recorders []io.Writer
+	if recording := c.record(slide); recording != nil {
+		recorders = append(recorders, recording)
+		defer func() { _ = recording.Close() }()
+	}
 
-	writer := newWSWriter(ctx, c.ws, &c.wsMu)
-	cmd.Stdout = writer
-	cmd.Stderr = writer
+	succeeded := false
+	if fallback := c.recordFallback(slide); fallback != nil {
+		recorders = append(recorders, fallback)
+		defer func() { c.keepFallback(fallback, slide, succeeded) }()
+	}
+
+	if len(recorders) > 0 {
+		terminal.recorder = io.MultiWriter(recorders...)
+	}
+
+	// the limits only apply to the command so it is typed first, the displayed lines are already redacted
+	if typewriter := c.typewriter(slide); typewriter.Enabled {
+		if err := typeCommand(ctx, terminal, slide.Content, typewriter); err != nil {
+			c.logger.Info("command stopped while typing", "error", err)
+			return
+		}
+	}
+
+	// the presenter stopping the command is the only way the original context is cancelled
+	presenter := ctx
+	ctx, stopForLimit := context.WithCancelCause(ctx)
+	defer stopForLimit(nil)
 
-	if err := cmd.Run(); err != nil {
+	if limits.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, limits.Timeout, &limitError{limit: "timeout", value: limits.Timeout.String()})
+		defer cancel()
+	}
+
+	outputs := []io.Writer{output, terminal}
+	capture := c.capture()
+	if capture != nil {
+		outputs = append(outputs, capture)
+	}
+
+	redacted := c.redactor.Writer(io.MultiWriter(outputs...))
+	writer := newLimitWriter(redacted, limits.MaxOutput, func() {
+		stopForLimit(&limitError{limit: "output", value: limits.MaxOutput.String()})
+	})
+
+	start := time.Now()
+	var err error
+	if len(slide.Processes) > 0 {
+		err = c.runProcesses(ctx, slide, limits, writer)
+	} else {
+		cmd := c.command(ctx, slide, limits)
+		cmd.Stdout = writer
+		cmd.Stderr = writer
+
+		err = cmd.Run()
+		if ctx.Err() != nil {
+			// clean up anything the command left running in the background
+			killProcessGroup(cmd)
+		}
+	}
+	_ = redacted.Close()
+
+	if err != nil {
 		if ctx.Err() != nil {
-			c.logger.Info("command stopped", "reason", ctx.Err())
+			c.logger.Info("command stopped", "reason", context.Cause(ctx))
 		} else {
 			c.logger.Error("command failed", "error", err)
 		}
 	} else {
 		c.logger.Info("command completed")
 	}
+
+	reason := c.reportLimit(ctx, err, limits, output, terminal)
+	if reason == nil && presenter.Err() != nil {
+		reason = errStopped
+	}
+	c.transcribeRun(slide, client, start, err, reason, capture)
+
+	succeeded = err == nil && ctx.Err() == nil
+	if err != nil && presenter.Err() == nil {
+		c.fallBack(presenter, slide, output)
+	}
 }
 
-func (c *commandManager) Run(commands []string) (err error) {
+// reportLimit shows the limit that stopped a command, if it was stopped by one, in the terminal and on its run button
+// and returns it
+func (c *commandManager) reportLimit(ctx context.Context, err error, limits types.Limits, output *outputTail, terminal io.Writer) (hit error) {
+	var limitErr *limitError
+	if ctx.Err() == nil {
+		limitErr = failedLimit(err, limits, output.preview())
+	} else {
+		errors.As(context.Cause(ctx), &limitErr)
+	}
+
+	if limitErr == nil {
+		return
+	}
+
+	c.logger.Warn("command hit a limit", "limit", limitErr.limit, "error", limitErr)
+
+	output.mu.Lock()
+	output.limitHit = limitErr.Error()
+	output.mu.Unlock()
+
+	// the terminal writer uses the original context so the message is still shown after the command was stopped for the limit
+	_, _ = fmt.Fprintf(terminal, "\n\033[1;31m%v\033[0m\n", limitErr)
+	return limitErr
+}
+
+func (c *commandManager) Run(slide types.Slide, client string) (err error) {
+	if slide.Steps {
+		return c.step(slide, client)
+	}
+
 	_ = c.Stop()
 
 	if !c.IsWebsocketConnected() {
@@ -183,8 +574,13 @@ func (c *commandManager) Run(commands []string) (err error) {
 	var ctx context.Context
 	ctx, c.cancel = context.WithCancel(context.Background())
 
-	script := strings.Join(commands, "\n")
-	go c.run(ctx, script)
+	c.wg.Add(1)
+	if slide.SlideType == types.SlideTypePlayback {
+		go c.play(ctx, slide, c.resetOutput(slide.ID))
+		return
+	}
+
+	go c.run(ctx, slide, client, c.settings.Limits.Override(slide.Limits), c.resetOutput(slide.ID))
 
 	return
 }
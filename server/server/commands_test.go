@@ -1,24 +1,31 @@
 package server
 
 import (
+	"context"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/joshjennings98/backend-demo/server/v2/types"
 )
 
 func setupWebSocket(t *testing.T, cm ICommandManager) (ws *websocket.Conn, cleanup func()) {
 	t.Helper()
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		upgrader := newUpgrader(true)
+		upgrader := newUpgrader(true, nil)
 		ws, err := upgrader.Upgrade(w, r, nil)
 		assert.NoError(t, err)
 		cm.SetWebsocketConnection(ws)
@@ -40,15 +47,41 @@ func setupWebSocket(t *testing.T, cm ICommandManager) (ws *websocket.Conn, clean
 	return
 }
 
+func TestIsAllowedOrigin(t *testing.T) {
+	testCases := []struct {
+		origin         string
+		allowedOrigins []string
+		allowed        bool
+	}{
+		{origin: "http://localhost:8080", allowed: true},
+		{origin: "http://127.0.0.1:8080", allowed: true},
+		{origin: "https://localhost:8443", allowed: true},
+		{origin: "http://localhost", allowed: false},
+		{origin: "http://localhost.evil.com:8080", allowed: false},
+		{origin: "http://devbox:8080", allowed: false},
+		{origin: "http://devbox:8080", allowedOrigins: []string{"http://devbox:8080"}, allowed: true},
+		{origin: "https://demo.example.com", allowedOrigins: []string{"https://Demo.Example.com/"}, allowed: true},
+		{origin: "https://demo.example.com", allowedOrigins: []string{"http://demo.example.com"}, allowed: false},
+		{origin: "https://anything.com", allowedOrigins: []string{"*"}, allowed: true},
+		{origin: "", allowed: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.origin, func(t *testing.T) {
+			assert.Equal(t, tc.allowed, isAllowedOrigin(tc.origin, tc.allowedOrigins))
+		})
+	}
+}
+
 func TestCommandManager_Stop(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
-	cm := newCommandManager(logger)
+	cm := newCommandManager(logger, types.Settings{}, nil)
 
 	_, cleanup := setupWebSocket(t, cm)
 	defer cleanup()
 
 	// start a long-running command
-	err := cm.Run([]string{"sleep 10"})
+	err := cm.Run(types.Slide{ExecuteContent: []string{"sleep 10"}}, "")
 	require.NoError(t, err)
 
 	time.Sleep(100 * time.Millisecond)
@@ -62,9 +95,59 @@ func TestCommandManager_Stop(t *testing.T) {
 	assert.False(t, cm.IsRunning())
 }
 
+func TestCommandManager_Shutdown(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	cm := newCommandManager(logger, types.Settings{}, nil)
+
+	ws, cleanup := setupWebSocket(t, cm)
+	defer cleanup()
+
+	pidFile := filepath.Join(t.TempDir(), "pid")
+
+	// the background sleep should be stopped along with the command that started it
+	err := cm.Run(types.Slide{ExecuteContent: []string{"sleep 30 &", "echo $! > " + pidFile, "wait"}}, "")
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(pidFile)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	contents, err := os.ReadFile(pidFile)
+	require.NoError(t, err)
+	pid, err := strconv.Atoi(strings.TrimSpace(string(contents)))
+	require.NoError(t, err)
+
+	err = cm.Shutdown(context.Background())
+	require.NoError(t, err)
+	assert.False(t, cm.IsRunning())
+	assert.False(t, cm.IsWebsocketConnected())
+	assert.Eventually(t, func() bool { return !isProcessAlive(pid) }, time.Second, 10*time.Millisecond, "background process should have been killed")
+
+	_, _, err = ws.ReadMessage() // clear
+	require.NoError(t, err)
+	_, _, err = ws.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway))
+}
+
+// isProcessAlive checks whether a process exists and hasn't exited, killed orphans can remain as zombies until they are reaped
+func isProcessAlive(pid int) bool {
+	if syscall.Kill(pid, 0) != nil {
+		return false
+	}
+
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%v/stat", pid))
+	if err != nil {
+		return true
+	}
+
+	_, fields, _ := strings.Cut(string(stat), ") ")
+	return !strings.HasPrefix(fields, "Z")
+}
+
 func TestCommandManager_Clear(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
-	cm := newCommandManager(logger)
+	cm := newCommandManager(logger, types.Settings{}, nil)
 
 	_, cleanup := setupWebSocket(t, cm)
 	defer cleanup()
@@ -75,12 +158,12 @@ func TestCommandManager_Clear(t *testing.T) {
 
 func TestCommandManager_Run(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
-	cm := newCommandManager(logger)
+	cm := newCommandManager(logger, types.Settings{}, nil)
 
 	ws, cleanup := setupWebSocket(t, cm)
 	defer cleanup()
 
-	err := cm.Run([]string{"echo hello"})
+	err := cm.Run(types.Slide{ExecuteContent: []string{"echo hello"}}, "")
 	require.NoError(t, err)
 
 	// read the clear message
@@ -96,13 +179,13 @@ func TestCommandManager_Run(t *testing.T) {
 
 func TestCommandManager_RunMultipleCommands(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
-	cm := newCommandManager(logger)
+	cm := newCommandManager(logger, types.Settings{}, nil)
 
 	ws, cleanup := setupWebSocket(t, cm)
 	defer cleanup()
 
 	// run multiple commands meaning env var should persist
-	err := cm.Run([]string{"export FOO=bar", "echo $FOO"})
+	err := cm.Run(types.Slide{ExecuteContent: []string{"export FOO=bar", "echo $FOO"}}, "")
 	require.NoError(t, err)
 
 	_, _, err = ws.ReadMessage() // clear
@@ -114,9 +197,96 @@ func TestCommandManager_RunMultipleCommands(t *testing.T) {
 	assert.Contains(t, string(msg), "bar")
 }
 
+func TestCommandManager_Typewriter(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	cm := newCommandManager(logger, types.Settings{Typewriter: types.Typewriter{Enabled: true, Speed: time.Microsecond}}, nil)
+
+	ws, cleanup := setupWebSocket(t, cm)
+	defer cleanup()
+
+	err := cm.Run(types.Slide{Content: "echo typed", ExecuteContent: []string{"echo typed"}}, "")
+	require.NoError(t, err)
+	assert.Contains(t, readTerminal(t, ws, "typed\n\r"+"typed"), "$ echo typed\n\r")
+
+	// the slide can turn it off
+	err = cm.Run(types.Slide{Content: "echo plain", ExecuteContent: []string{"echo plain"}, Typewriter: &types.Typewriter{}}, "")
+	require.NoError(t, err)
+	assert.NotContains(t, readTerminal(t, ws, "plain"), "$ ")
+}
+
+func TestCommandManager_LastOutput(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	cm := newCommandManager(logger, types.Settings{}, nil)
+
+	_, cleanup := setupWebSocket(t, cm)
+	defer cleanup()
+
+	assert.Empty(t, cm.LastOutput(3))
+
+	err := cm.Run(types.Slide{ID: 3, ExecuteContent: []string{"printf '\\033[32mgreen\\033[0m\\n'", "seq 1 10"}}, "")
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return !cm.IsRunning() && cm.LastOutput(3) != "" }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "5\n6\n7\n8\n9\n10", cm.LastOutput(3))
+	assert.Empty(t, cm.LastOutput(4))
+}
+
+func TestOutputTail(t *testing.T) {
+	output := &outputTail{}
+
+	_, err := output.Write([]byte("\033[1mfirst\033[0m\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "first", output.preview())
+
+	_, err = output.Write([]byte(strings.Repeat("x", outputTailSize)))
+	require.NoError(t, err)
+	assert.Len(t, output.buf, outputTailSize)
+	assert.Equal(t, strings.Repeat("x", outputTailSize), output.preview())
+}
+
+func TestCommandManager_Settings(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	dir := t.TempDir()
+	cm := newCommandManager(logger, types.Settings{
+		Shell:      "bash",
+		WorkingDir: dir,
+		Env:        map[string]string{"GREETING": "hello"},
+	}, nil)
+
+	ws, cleanup := setupWebSocket(t, cm)
+	defer cleanup()
+
+	err := cm.Run(types.Slide{ExecuteContent: []string{"[[ -n $BASH_VERSION ]] && echo \"$GREETING from $PWD\""}}, "")
+	require.NoError(t, err)
+
+	_, _, err = ws.ReadMessage() // clear
+	require.NoError(t, err)
+
+	_, msg, err := ws.ReadMessage()
+	require.NoError(t, err)
+	assert.Contains(t, string(msg), "hello from "+dir)
+}
+
+func TestLogWriter(t *testing.T) {
+	var logs strings.Builder
+	writer := newLogWriter(slog.New(slog.NewTextHandler(&logs, nil)), "setup")
+
+	_, err := writer.Write([]byte("first line\nsecond "))
+	require.NoError(t, err)
+	assert.Contains(t, logs.String(), `output="first line"`)
+	assert.NotContains(t, logs.String(), "second")
+
+	_, err = writer.Write([]byte("line\r\nunterminated"))
+	require.NoError(t, err)
+	assert.Contains(t, logs.String(), `output="second line"`)
+
+	writer.Flush()
+	assert.Contains(t, logs.String(), `output=unterminated`)
+}
+
 func TestCommandManager_WebSocketConnection(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
-	cm := newCommandManager(logger)
+	cm := newCommandManager(logger, types.Settings{}, nil)
 
 	assert.False(t, cm.IsWebsocketConnected())
 
@@ -129,3 +299,228 @@ func TestCommandManager_WebSocketConnection(t *testing.T) {
 	require.NoError(t, err)
 	assert.False(t, cm.IsWebsocketConnected())
 }
+
+func TestCommandManager_Limits(t *testing.T) {
+	testCases := []struct {
+		name     string
+		settings types.Limits
+		slide    types.Slide
+		limitHit string
+	}{
+		{
+			name:     "Timeout",
+			settings: types.Limits{Timeout: 100 * time.Millisecond},
+			slide:    types.Slide{ID: 1, ExecuteContent: []string{"sleep 10"}},
+			limitHit: "timeout limit of 100ms exceeded",
+		},
+		{
+			name:     "Slide overrides presentation",
+			settings: types.Limits{Timeout: time.Minute},
+			slide:    types.Slide{ID: 1, ExecuteContent: []string{"sleep 10"}, Limits: types.Limits{Timeout: 100 * time.Millisecond}},
+			limitHit: "timeout limit of 100ms exceeded",
+		},
+		{
+			name:     "Output",
+			slide:    types.Slide{ID: 1, ExecuteContent: []string{"yes"}, Limits: types.Limits{MaxOutput: 64 * 1024}},
+			limitHit: "output limit of 64K exceeded",
+		},
+		{
+			name:     "CPU",
+			slide:    types.Slide{ID: 1, ExecuteContent: []string{"while :; do :; done"}, Limits: types.Limits{CPU: time.Second}},
+			limitHit: "cpu limit of 1s exceeded",
+		},
+		{
+			name:     "Open files",
+			slide:    types.Slide{ID: 1, ExecuteContent: []string{"exec 3</dev/null 4</dev/null 5</dev/null 6</dev/null 7</dev/null"}, Limits: types.Limits{OpenFiles: 4}},
+			limitHit: "open files limit of 4 exceeded",
+		},
+		{
+			name:  "Within limits",
+			slide: types.Slide{ID: 1, ExecuteContent: []string{"echo done"}, Limits: types.Limits{Timeout: time.Minute, CPU: time.Minute, Memory: 1 << 30, OpenFiles: 64, MaxOutput: 1024}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+			cm := newCommandManager(logger, types.Settings{Limits: tc.settings}, nil)
+
+			ws, cleanup := setupWebSocket(t, cm)
+			defer cleanup()
+
+			messages := make(chan string, 1024)
+			go func() {
+				for {
+					_, msg, err := ws.ReadMessage()
+					if err != nil {
+						close(messages)
+						return
+					}
+					messages <- string(msg)
+				}
+			}()
+
+			err := cm.Run(tc.slide, "")
+			require.NoError(t, err)
+
+			require.Eventually(t, func() bool { return !cm.IsRunning() }, 5*time.Second, 10*time.Millisecond)
+			assert.Equal(t, tc.limitHit, cm.Result(tc.slide.ID).LimitHit)
+
+			if tc.limitHit != "" {
+				assert.Eventually(t, func() bool {
+					for {
+						select {
+						case msg := <-messages:
+							if strings.Contains(msg, tc.limitHit) {
+								return true
+							}
+						default:
+							return false
+						}
+					}
+				}, time.Second, 10*time.Millisecond, "terminal should show which limit was hit")
+			}
+		})
+	}
+}
+
+func TestCommandManager_Redaction(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	settings := types.Settings{
+		Env:    map[string]string{"API_TOKEN": "s3cret-value"},
+		Redact: types.RedactSettings{Env: []string{"API_TOKEN"}},
+	}
+	redactor, err := newRedactor(settings)
+	require.NoError(t, err)
+	cm := newCommandManager(logger, settings, redactor)
+
+	ws, cleanup := setupWebSocket(t, cm)
+	defer cleanup()
+
+	// the secret is written in two parts so it arrives in separate chunks
+	err = cm.Run(types.Slide{ID: 1, ExecuteContent: []string{"printf 'token: s3cr'", "sleep 0.01", "printf 'et-value\\n'", "printf 'Password: '"}}, "")
+	require.NoError(t, err)
+
+	_, _, err = ws.ReadMessage() // clear
+	require.NoError(t, err)
+
+	var output strings.Builder
+	for !strings.Contains(output.String(), "Password: ") {
+		_, msg, err := ws.ReadMessage()
+		require.NoError(t, err)
+		output.Write(msg)
+	}
+
+	assert.Contains(t, output.String(), "token: ********")
+	assert.NotContains(t, output.String(), "s3cr")
+	assert.Eventually(t, func() bool { return cm.LastOutput(1) == "token: ********\nPassword: " }, time.Second, 10*time.Millisecond)
+}
+
+func TestCommandManager_Environment(t *testing.T) {
+	t.Setenv("SERVER_ONLY", "leaked")
+	t.Setenv("KEPT", "inherited")
+
+	dir := t.TempDir()
+	slideDir := filepath.Join(dir, "slide")
+	require.NoError(t, os.Mkdir(slideDir, 0o700))
+
+	testCases := []struct {
+		name     string
+		settings types.Settings
+		slide    types.Slide
+		expected string
+	}{
+		{
+			name:     "Inherited by default",
+			settings: types.Settings{WorkingDir: dir},
+			slide:    types.Slide{},
+			expected: "leaked|inherited||" + dir,
+		},
+		{
+			name:     "Slide overrides presentation",
+			settings: types.Settings{WorkingDir: dir, Env: map[string]string{"GREETING": "hello", "KEPT": "presentation"}},
+			slide:    types.Slide{WorkingDir: slideDir, Env: map[string]string{"GREETING": "hi"}},
+			expected: "leaked|presentation|hi|" + slideDir,
+		},
+		{
+			name:     "Clean environment",
+			settings: types.Settings{WorkingDir: dir, CleanEnv: true, InheritEnv: []string{"PATH", "KEPT"}, Env: map[string]string{"GREETING": "hello"}},
+			slide:    types.Slide{},
+			expected: "|inherited|hello|" + dir,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+			cm := newCommandManager(logger, tc.settings, nil)
+
+			tc.slide.ExecuteContent = []string{`echo "$SERVER_ONLY|$KEPT|$GREETING|$PWD"`}
+			cmd := cm.(*commandManager).command(context.Background(), tc.slide, types.Limits{})
+			out, err := cmd.Output()
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, strings.TrimSpace(string(out)))
+		})
+	}
+}
+
+func TestInterpreterArgs(t *testing.T) {
+	testCases := []struct {
+		shell    string
+		expected []string
+	}{
+		{shell: "sh", expected: []string{"sh", "-c", "ls"}},
+		{shell: "bash -eu", expected: []string{"bash", "-eu", "-c", "ls"}},
+		{shell: "/usr/bin/zsh", expected: []string{"/usr/bin/zsh", "-c", "ls"}},
+		{shell: "python3", expected: []string{"python3", "-c", "ls"}},
+		{shell: "python3.12 -u", expected: []string{"python3.12", "-u", "-c", "ls"}},
+		{shell: "node", expected: []string{"node", "-e", "ls"}},
+		{shell: "ruby", expected: []string{"ruby", "-e", "ls"}},
+		{shell: "pwsh.exe", expected: []string{"pwsh.exe", "-Command", "ls"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.shell, func(t *testing.T) {
+			assert.Equal(t, tc.expected, interpreterArgs(tc.shell, "ls"))
+		})
+	}
+}
+
+func TestCommandManager_Interpreter(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	cm := newCommandManager(logger, types.Settings{Shell: "bash"}, nil)
+
+	cmd := cm.(*commandManager).command(context.Background(), types.Slide{ExecuteContent: []string{`echo "$0"`}}, types.Limits{})
+	out, err := cmd.Output()
+	require.NoError(t, err)
+	assert.Equal(t, "bash", strings.TrimSpace(string(out)))
+
+	cmd = cm.(*commandManager).command(context.Background(), types.Slide{Shell: "sh", ExecuteContent: []string{`echo "$0"`}}, types.Limits{})
+	out, err = cmd.Output()
+	require.NoError(t, err)
+	assert.Equal(t, "sh", strings.TrimSpace(string(out)))
+}
+
+func TestCommandManager_CheckInterpreters(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	cm := newCommandManager(logger, types.Settings{Shell: "sh"}, nil)
+
+	slides := []types.Slide{
+		{ID: 0, SlideType: types.SlideTypeCommand},
+		{ID: 1, SlideType: types.SlideTypePlain, Shell: "not-an-interpreter"},
+		{ID: 2, SlideType: types.SlideTypeCommand, Shell: "bash -eu"},
+	}
+	assert.NoError(t, cm.CheckInterpreters(slides))
+
+	slides = append(slides, types.Slide{ID: 3, SlideType: types.SlideTypeCommand, Shell: "not-an-interpreter -x"})
+	assert.ErrorContains(t, cm.CheckInterpreters(slides), "could not find shell or interpreter: not-an-interpreter (slide 4)")
+
+	cm = newCommandManager(logger, types.Settings{Shell: "not-a-shell"}, nil)
+	assert.ErrorContains(t, cm.CheckInterpreters(slides[:1]), "not-a-shell (slide 1)")
+
+	// the setup and teardown commands run with the presentation's shell even when no slide does
+	cm = newCommandManager(logger, types.Settings{Shell: "not-a-shell", Teardown: []string{"echo bye"}}, nil)
+	assert.ErrorContains(t, cm.CheckInterpreters(slides[2:3]), "could not find shell or interpreter: not-a-shell (setup and teardown)")
+	cm = newCommandManager(logger, types.Settings{Shell: "not-a-shell"}, nil)
+	assert.NoError(t, cm.CheckInterpreters(slides[2:3]))
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// parseDotEnv reads KEY=value lines in the format of .env files. Lines can start with export, values can be single
+// or double quoted and anything after a # is a comment unless it is quoted.
+func parseDotEnv(r io.Reader) (env map[string]string, err error) {
+	env = map[string]string{}
+
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			err = fmt.Errorf("line %v is not KEY=value", lineNumber)
+			return
+		}
+
+		value = strings.TrimSpace(value)
+		switch {
+		case strings.HasPrefix(value, `"`):
+			if value, err = strconv.Unquote(value); err != nil {
+				err = fmt.Errorf("line %v has an invalid quoted value: %w", lineNumber, err)
+				return
+			}
+		case strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'") && len(value) > 1:
+			value = value[1 : len(value)-1]
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+
+		env[key] = value
+	}
+
+	err = scanner.Err()
+	return
+}
+
+func loadDotEnv(path string) (env map[string]string, err error) {
+	env, _, err = readDotEnv(path)
+	return
+}
+
+// readDotEnv loads a .env file, returning its contents as well as the variables
+func readDotEnv(path string) (env map[string]string, contents []byte, err error) {
+	contents, err = os.ReadFile(path)
+	if err != nil {
+		err = fmt.Errorf("could not open env file '%v': %w", path, err)
+		return
+	}
+
+	if env, err = parseDotEnv(bytes.NewReader(contents)); err != nil {
+		err = fmt.Errorf("could not parse env file '%v': %w", path, err)
+	}
+
+	return
+}
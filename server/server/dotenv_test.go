@@ -0,0 +1,47 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDotEnv(t *testing.T) {
+	env, err := parseDotEnv(strings.NewReader(`
+# staging credentials
+API_TOKEN=abc123
+export REGION = eu-west-1 # inline comment
+GREETING="hello # world\n"
+RAW='$NOT_EXPANDED'
+EMPTY=
+`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"API_TOKEN": "abc123",
+		"REGION":    "eu-west-1",
+		"GREETING":  "hello # world\n",
+		"RAW":       "$NOT_EXPANDED",
+		"EMPTY":     "",
+	}, env)
+
+	_, err = parseDotEnv(strings.NewReader("API_TOKEN=abc\nnot a variable\n"))
+	assert.ErrorContains(t, err, "line 2 is not KEY=value")
+
+	_, err = parseDotEnv(strings.NewReader(`QUOTED="unterminated`))
+	assert.ErrorContains(t, err, "line 1 has an invalid quoted value")
+}
+
+func TestParseEnvDirective(t *testing.T) {
+	env, err := parseEnvDirective(`STAGE=dev MESSAGE="hello \"world\"" EMPTY=`)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"STAGE": "dev", "MESSAGE": `hello "world"`, "EMPTY": ""}, env)
+
+	env, err = parseEnvDirective("")
+	require.NoError(t, err)
+	assert.Nil(t, env)
+
+	_, err = parseEnvDirective("STAGE=dev oops")
+	assert.ErrorContains(t, err, "'oops' is not KEY=value")
+}
@@ -0,0 +1,295 @@
+package server
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maragudk/gomponents"
+	"github.com/maragudk/gomponents/html"
+	xhtml "golang.org/x/net/html"
+
+	"github.com/joshjennings98/backend-demo/server/v2/types"
+)
+
+// ExportHTML writes the presentation in the commands file as a single HTML file that can be viewed without the server.
+// The scripts, styles and files embedded in the slides are included in it, along with any recorded output of the
+// commands which can be played in the terminal.
+func ExportHTML(logger *slog.Logger, commandsFile string, w io.Writer, opts ...Option) (err error) {
+	srv, err := newServer(logger, 0, commandsFile, append(opts, WithExecutionDisabled())...)
+	if err != nil {
+		return
+	}
+
+	return srv.exportHTML(w)
+}
+
+func (s *server) exportHTML(w io.Writer) (err error) {
+	if len(s.slides) == 0 {
+		return errors.New("there aren't any slides to export")
+	}
+
+	root, err := os.OpenRoot(filepath.Dir(s.commandsFile))
+	if err != nil {
+		err = fmt.Errorf("could not open presentation directory: %w", err)
+		return
+	}
+	defer root.Close()
+
+	assets, err := s.inlineStatic(root)
+	if err != nil {
+		return
+	}
+
+	slides := slices.Clone(s.slides)
+	recordings := map[int][]sessionEvent{}
+	for i, slide := range slides {
+		if len(slide.Assets) > 0 {
+			slide.Content = s.inlineAssets(root, slide.Content)
+		}
+
+		// slides with recorded output are played back like playback slides, and other commands can't be run
+		if hasTerminal(slide.SlideType) {
+			events := s.exportRecording(slide)
+			slide.SlideType = types.SlideTypeCommand
+			if len(events) > 0 {
+				recordings[slide.ID] = events
+				slide.SlideType = types.SlideTypePlayback
+			}
+		}
+
+		slides[i] = slide
+	}
+
+	data, err := json.Marshal(recordings)
+	if err != nil {
+		err = fmt.Errorf("could not encode recordings: %w", err)
+		return
+	}
+
+	acc := access{offline: true}
+	titles := s.GetSlideTitles()
+	var templates []gomponents.Node
+	for _, slide := range slides {
+		templates = append(templates, gomponents.El("template",
+			html.ID(fmt.Sprintf("slide-%v", slide.ID)),
+			contentDiv(slide.ID, titles, slide, false, acc),
+		))
+	}
+
+	return pageHTML(s.settings, acc, assets,
+		contentDiv(0, titles, slides[0], false, acc),
+		gomponents.Group(templates),
+		// the encoded JSON can't end the script early since it escapes <
+		html.Script(html.Type("application/json"), html.ID("recordings"), gomponents.Raw(string(data))),
+	).Render(w)
+}
+
+// inlineStatic returns the scripts and styles that every page loads, and the theme, to include in the page
+func (s *server) inlineStatic(root *os.Root) (assets gomponents.Node, err error) {
+	var nodes []gomponents.Node
+	for _, script := range staticScripts {
+		var data []byte
+		if data, err = staticFS.ReadFile("static/" + script); err != nil {
+			return
+		}
+		nodes = append(nodes, html.Script(gomponents.Raw(strings.ReplaceAll(string(data), "</script", `<\/script`))))
+	}
+
+	for _, style := range staticStyles {
+		var data []byte
+		if data, err = staticFS.ReadFile("static/" + style); err != nil {
+			return
+		}
+		nodes = append(nodes, html.StyleEl(gomponents.Raw(string(data))))
+	}
+
+	if theme := s.settings.Theme; strings.HasSuffix(theme, ".css") {
+		var data []byte
+		if data, err = fs.ReadFile(root.FS(), path.Clean(filepath.ToSlash(theme))); err != nil {
+			err = fmt.Errorf("could not read theme: %w", err)
+			return
+		}
+		nodes = append(nodes, html.StyleEl(gomponents.Raw(string(data))))
+	}
+
+	assets = gomponents.Group(nodes)
+	return
+}
+
+// inlineAssets replaces the local files embedded in the HTML of a slide with data URIs, files that can't be read are
+// left as they are
+func (s *server) inlineAssets(root *os.Root, content string) string {
+	var b strings.Builder
+	tokenizer := xhtml.NewTokenizer(strings.NewReader(content))
+	for {
+		kind := tokenizer.Next()
+		if kind == xhtml.ErrorToken {
+			return b.String()
+		}
+
+		raw := string(tokenizer.Raw())
+		if kind != xhtml.StartTagToken && kind != xhtml.SelfClosingTagToken {
+			b.WriteString(raw)
+			continue
+		}
+
+		token := tokenizer.Token()
+		changed := false
+		for i, attr := range token.Attr {
+			if !slices.Contains(assetAttributes[token.Data], attr.Key) {
+				continue
+			}
+
+			refs := []string{attr.Val}
+			if attr.Key == "srcset" {
+				refs = strings.Split(attr.Val, ",")
+			}
+
+			for j, ref := range refs {
+				fields := strings.Fields(ref)
+				if len(fields) == 0 {
+					continue
+				}
+				if uri, ok := s.dataURI(root, fields[0]); ok {
+					fields[0] = uri
+					changed = true
+				}
+				refs[j] = strings.Join(fields, " ")
+			}
+			token.Attr[i].Val = strings.Join(refs, ", ")
+		}
+
+		if changed {
+			b.WriteString(token.String())
+		} else {
+			b.WriteString(raw)
+		}
+	}
+}
+
+// dataURI reads a file embedded in a slide as a data URI
+func (s *server) dataURI(root *os.Root, ref string) (uri string, ok bool) {
+	name, ok := localAssetPath(ref)
+	if !ok || !s.isServableAsset(name) {
+		return "", false
+	}
+
+	data, err := fs.ReadFile(root.FS(), name)
+	if err != nil {
+		s.logger.Warn("could not include file in export", "file", name, "error", err)
+		return "", false
+	}
+
+	kind := mime.TypeByExtension(path.Ext(name))
+	if kind == "" {
+		kind = http.DetectContentType(data)
+	}
+
+	return fmt.Sprintf("data:%v;base64,%v", kind, base64.StdEncoding.EncodeToString(data)), true
+}
+
+// recordings returns the recordings that could have the output of a slide's command, the recording a playback slide
+// replays, or the output of the last successful run of a command followed by the recordings of the slide from the
+// newest
+func (s *server) recordings(slide types.Slide) (paths []string) {
+	if slide.SlideType == types.SlideTypePlayback {
+		return []string{slide.Recording}
+	}
+
+	if s.settings.Fallback.Dir != "" {
+		paths = append(paths, fallbackPath(s.settings, slide))
+	}
+
+	if s.settings.Record.Dir != "" {
+		// recordings are named by slide and then time so the newest sorts last
+		matches, _ := filepath.Glob(filepath.Join(s.settings.Record.Dir, fmt.Sprintf("slide-%02d-*.cast", slide.ID+1)))
+		slices.Sort(matches)
+		slices.Reverse(matches)
+		paths = append(paths, matches...)
+	}
+
+	return
+}
+
+// exportRecording returns the recorded output of a slide's command, if there is any, as output events at the times
+// they are shown with the slide's playback settings
+func (s *server) exportRecording(slide types.Slide) (events []sessionEvent) {
+	for _, recording := range s.recordings(slide) {
+		header, cast, err := loadCast(recording)
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) || slide.SlideType == types.SlideTypePlayback {
+				s.logger.Warn("could not include recording in export", "slide", slide.ID, "error", err)
+			}
+			continue
+		}
+
+		// the slide might have moved or changed since it was recorded
+		if slide.SlideType != types.SlideTypePlayback && header.Command != slide.Content {
+			continue
+		}
+
+		return s.timeRecording(cast, slide.Playback)
+	}
+
+	return
+}
+
+// timeRecording redacts the output of a recording and times it with the playback settings
+func (s *server) timeRecording(cast []castEvent, playback types.Playback) []sessionEvent {
+	output := &eventWriter{}
+	redacted := s.redactor.Writer(output)
+
+	speed := cmp.Or(playback.Speed, 1)
+	var elapsed, previous time.Duration
+	for _, event := range cast {
+		gap := event.time - previous
+		previous = event.time
+		if playback.IdleLimit > 0 {
+			gap = min(gap, playback.IdleLimit)
+		}
+		elapsed += time.Duration(float64(gap) / speed)
+
+		output.at(elapsed)
+		_, _ = io.WriteString(redacted, event.data)
+	}
+	_ = redacted.Close()
+
+	return output.events
+}
+
+// eventWriter keeps what is written to it as output events at the time it was last set to
+type eventWriter struct {
+	mu     sync.Mutex
+	time   float64
+	events []sessionEvent
+}
+
+func (w *eventWriter) at(elapsed time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.time = elapsed.Seconds()
+}
+
+func (w *eventWriter) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.events = append(w.events, sessionEvent{Time: w.time, Type: SessionEventOutput, Data: string(p)})
+	n = len(p)
+	return
+}
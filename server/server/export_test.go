@@ -0,0 +1,131 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportHTML(t *testing.T) {
+	dir := t.TempDir()
+	commands := filepath.Join(dir, "commands.txt")
+	require.NoError(t, os.WriteFile(commands, []byte(`---
+title: Exported
+theme: theme.css
+env:
+  TOKEN: s3cret-value
+redact:
+  env: [TOKEN]
+record:
+  dir: recordings
+fallback:
+  record: true
+---
+
+# Intro <img src="logo.png" srcset="logo.png 2x, https://example.com/logo.png 3x">
+
+$ echo hello
+
+$ echo other
+
+$ echo never run
+
+<!-- play: deploy.cast -->
+<!-- speed: 2 -->
+<!-- idle-limit: 1s -->
+$ ./deploy.sh
+`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "logo.png"), []byte("\x89PNG\r\n\x1a\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "theme.css"), []byte("body { color: hotpink; }"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "deploy.cast"), []byte(`{"version": 2, "width": 80, "height": 24}
+[1, "o", "deploying\r\n"]
+[11, "o", "token s3cret-value\r\n"]
+`), 0o600))
+
+	srv, err := newServer(slog.New(slog.NewTextHandler(os.Stdout, nil)), 0, commands, WithExecutionDisabled())
+	require.NoError(t, err)
+	require.Len(t, srv.slides, 5)
+
+	// the last successful run of the first command and a recording of the second
+	require.NoError(t, os.MkdirAll(srv.settings.Fallback.Dir, 0o750))
+	require.NoError(t, os.WriteFile(fallbackPath(srv.settings, srv.slides[1]), []byte(`{"version": 2, "width": 80, "height": 24, "command": "echo hello"}
+[0.5, "o", "hello\r\n"]
+`), 0o600))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "recordings"), 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "recordings", "slide-03-20250101-093000.000.cast"), []byte(`{"version": 2, "width": 80, "height": 24, "command": "echo other"}
+[0.25, "o", "other\r\n"]
+`), 0o600))
+	// a newer recording from when the slide had a different command
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "recordings", "slide-03-20250102-093000.000.cast"), []byte(`{"version": 2, "width": 80, "height": 24, "command": "echo changed"}
+[0.25, "o", "changed\r\n"]
+`), 0o600))
+
+	var buf bytes.Buffer
+	require.NoError(t, srv.exportHTML(&buf))
+	page := buf.String()
+
+	t.Run("Assets", func(t *testing.T) {
+		assert.NotContains(t, page, `src="static/`)
+		assert.NotContains(t, page, `href="static/`)
+		assert.Contains(t, page, "htmx")
+		assert.Contains(t, page, "body { color: hotpink; }")
+		assert.Contains(t, page, `src="data:image/png;base64,iVBORw0KGgo="`)
+		assert.Contains(t, page, `srcset="data:image/png;base64,iVBORw0KGgo= 2x, https://example.com/logo.png 3x"`)
+	})
+
+	t.Run("Slides", func(t *testing.T) {
+		assert.Contains(t, page, `data-offline="true"`)
+		for i := range srv.slides {
+			assert.Contains(t, page, fmt.Sprintf(`<template id="slide-%v">`, i))
+		}
+
+		// the contents and overview need the server
+		assert.NotContains(t, page, "/overview")
+		assert.NotContains(t, page, "/contents")
+		assert.Equal(t, 3, strings.Count(page, `<button data-recording=`))
+		assert.Equal(t, 1, strings.Count(page, "not recorded"))
+	})
+
+	t.Run("Recordings", func(t *testing.T) {
+		_, data, found := strings.Cut(page, `<script type="application/json" id="recordings">`)
+		require.True(t, found)
+		data, _, found = strings.Cut(data, "</script>")
+		require.True(t, found)
+
+		var recordings map[int][]sessionEvent
+		require.NoError(t, json.Unmarshal([]byte(data), &recordings))
+		assert.Equal(t, map[int][]sessionEvent{
+			1: {{Time: 0.5, Type: SessionEventOutput, Data: "hello\r\n"}},
+			2: {{Time: 0.25, Type: SessionEventOutput, Data: "other\r\n"}},
+			// the playback slide's speed and idle limit are applied, and secrets are redacted
+			4: {
+				{Time: 0.5, Type: SessionEventOutput, Data: "deploying\r\n"},
+				{Time: 1, Type: SessionEventOutput, Data: "token ********\r\n"},
+			},
+		}, recordings)
+		assert.NotContains(t, page, "s3cret-value")
+	})
+}
+
+func TestExportHTML_Errors(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	var buf bytes.Buffer
+	assert.ErrorContains(t, ExportHTML(logger, filepath.Join(t.TempDir(), "missing.txt"), &buf), "missing.txt")
+
+	commands := filepath.Join(t.TempDir(), "commands.txt")
+	require.NoError(t, os.WriteFile(commands, []byte("---\ntheme: missing.css\n---\n\n# Intro\n"), 0o600))
+	assert.ErrorContains(t, ExportHTML(logger, commands, &buf), "could not read theme")
+
+	require.NoError(t, os.WriteFile(commands, []byte("# Intro\n"), 0o600))
+	require.NoError(t, ExportHTML(logger, commands, &buf, WithSafeMode(nil)))
+	assert.Contains(t, buf.String(), "<h1>Intro</h1>")
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/joshjennings98/backend-demo/server/v2/types"
+)
+
+const (
+	FallbackOffer = "offer"
+	FallbackAuto  = "auto"
+	FallbackOff   = "off"
+
+	defaultFallbackDir = ".backend-demo"
+)
+
+var ErrNoFallback = errors.New("there is no output from a successful run to replay")
+
+// fallbackPath is where the output of the last successful run of a slide's command is kept, it is named after the
+// command rather than the slide so that it still matches when slides are added or moved, and doesn't when the command
+// is changed
+func (c *commandManager) fallbackPath(slide types.Slide) string {
+	return fallbackPath(c.settings, slide)
+}
+
+func fallbackPath(settings types.Settings, slide types.Slide) string {
+	hash := sha256.Sum256([]byte(Interpreter(settings, slide) + "\n" + strings.Join(slide.ExecuteContent, "\n")))
+	return filepath.Join(settings.Fallback.Dir, fmt.Sprintf("%x.cast", hash[:8]))
+}
+
+// hasFallback reports whether there is output from a successful run of a slide's command to replay
+func (c *commandManager) hasFallback(slide types.Slide) bool {
+	if c.settings.Fallback.Dir == "" {
+		return false
+	}
+
+	_, err := os.Stat(c.fallbackPath(slide))
+	return err == nil
+}
+
+// recordFallback starts recording a slide's command so its output can be kept if it succeeds, it returns nil when
+// this is turned off or the recording couldn't be created since that shouldn't stop the command from running
+func (c *commandManager) recordFallback(slide types.Slide) *castRecorder {
+	dir := c.settings.Fallback.Dir
+	if !c.settings.Fallback.Record || dir == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		c.logger.Warn("could not create fallback directory", "dir", dir, "error", err)
+		return nil
+	}
+
+	// the output is written to a file of its own first so that a failed run doesn't replace the last successful one
+	path := fmt.Sprintf("%v.%v.tmp", c.fallbackPath(slide), time.Now().UnixNano())
+	recording, err := newCastRecorder(path, c.castHeader(slide))
+	if err != nil {
+		c.logger.Warn("could not record command for fallback", "slide", slide.ID, "error", err)
+		return nil
+	}
+
+	return recording
+}
+
+// keepFallback keeps the recording of a slide's command in place of the last one if the command succeeded, and
+// removes it otherwise
+func (c *commandManager) keepFallback(recording *castRecorder, slide types.Slide, succeeded bool) {
+	path := recording.file.Name()
+	err := recording.Close()
+	if err == nil && succeeded {
+		if err = os.Rename(path, c.fallbackPath(slide)); err == nil {
+			c.logger.Info("kept output to replay if the command fails", "file", c.fallbackPath(slide))
+			return
+		}
+	}
+
+	if err != nil {
+		c.logger.Warn("could not keep output to replay if the command fails", "error", err)
+	}
+	_ = os.Remove(path)
+}
+
+// fallBack offers to replay, or replays, the output of the last successful run of a slide's command after it failed
+func (c *commandManager) fallBack(ctx context.Context, slide types.Slide, output *outputTail) {
+	if cmp.Or(c.settings.Fallback.Replay, FallbackOffer) == FallbackOff || !c.hasFallback(slide) {
+		return
+	}
+
+	if c.settings.Fallback.Replay == FallbackAuto {
+		c.replayFallback(ctx, slide, output)
+		return
+	}
+
+	output.mu.Lock()
+	output.canReplay = true
+	output.mu.Unlock()
+}
+
+func (c *commandManager) Replay(slide types.Slide) (err error) {
+	if !c.hasFallback(slide) {
+		return ErrNoFallback
+	}
+
+	_ = c.Stop()
+
+	if !c.IsWebsocketConnected() {
+		return
+	}
+
+	if err := c.Clear(); err != nil {
+		c.logger.Warn("failed to clear terminal", "error", err)
+	}
+
+	var ctx context.Context
+	ctx, c.cancel = context.WithCancel(context.Background())
+
+	output := c.resetOutput(slide.ID)
+	c.wg.Add(1)
+	go func() {
+		c.running.Store(true)
+		defer func() {
+			c.running.Store(false)
+			c.wg.Done()
+		}()
+
+		c.replayFallback(ctx, slide, output)
+	}()
+
+	return
+}
+
+// replayFallback replays the output of the last successful run of a slide's command between banners that make it
+// clear to the audience that it isn't live
+func (c *commandManager) replayFallback(ctx context.Context, slide types.Slide, output *outputTail) {
+	path := c.fallbackPath(slide)
+	c.logger.Warn("replaying output of the last successful run", "slide", slide.ID, "file", path)
+
+	output.mu.Lock()
+	output.canReplay = false
+	output.replayed = true
+	output.mu.Unlock()
+
+	terminal := c.terminal(ctx)
+	_, _ = fmt.Fprint(terminal, "\n\033[1;33m── replaying the output of the last successful run ──\033[0m\n")
+	if c.replay(ctx, path, types.Playback{}, output) {
+		_, _ = fmt.Fprint(terminal, "\n\033[1;33m── end of replay ──\033[0m\n")
+	}
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/joshjennings98/backend-demo/server/v2/types"
+)
+
+func TestCommandManager_Fallback(t *testing.T) {
+	for _, replay := range []string{FallbackOffer, FallbackAuto} {
+		t.Run(replay, func(t *testing.T) {
+			dir := t.TempDir()
+			fallbackDir := filepath.Join(dir, ".backend-demo")
+			status := filepath.Join(dir, "status")
+			require.NoError(t, os.WriteFile(status, []byte("all systems go\n"), 0o600))
+
+			logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+			cm := newCommandManager(logger, types.Settings{Fallback: types.FallbackSettings{Record: true, Dir: fallbackDir, Replay: replay}}, nil)
+
+			ws, cleanup := setupWebSocket(t, cm)
+			defer cleanup()
+
+			slide := types.Slide{ID: 1, Content: "cat status", ExecuteContent: []string{"cat " + status}}
+			assert.ErrorIs(t, cm.Replay(slide), ErrNoFallback)
+
+			// the output of a successful run is kept
+			require.NoError(t, cm.Run(slide, ""))
+			readTerminal(t, ws, "all systems go")
+			assert.Eventually(t, func() bool { return !cm.IsRunning() }, 5*time.Second, 10*time.Millisecond)
+
+			recordings, err := os.ReadDir(fallbackDir)
+			require.NoError(t, err)
+			require.Len(t, recordings, 1)
+			assert.Equal(t, types.CommandResult{}, cm.Result(slide.ID))
+
+			// a failed run doesn't replace it
+			require.NoError(t, os.Remove(status))
+			require.NoError(t, cm.Run(slide, ""))
+
+			if replay == FallbackAuto {
+				terminal := readTerminal(t, ws, "end of replay")
+				assert.Regexp(t, `(?s)No such file.*replaying the output of the last successful run.*all systems go`, terminal)
+				assert.Eventually(t, func() bool { return !cm.IsRunning() }, 5*time.Second, 10*time.Millisecond)
+				assert.Equal(t, types.CommandResult{Replayed: true}, cm.Result(slide.ID))
+			} else {
+				readTerminal(t, ws, "No such file")
+				assert.Eventually(t, func() bool { return cm.Result(slide.ID).CanReplay && !cm.IsRunning() }, 5*time.Second, 10*time.Millisecond)
+
+				require.NoError(t, cm.Replay(slide))
+				assert.Contains(t, readTerminal(t, ws, "end of replay"), "all systems go")
+				assert.Eventually(t, func() bool { return !cm.IsRunning() }, 5*time.Second, 10*time.Millisecond)
+				assert.Equal(t, types.CommandResult{Replayed: true}, cm.Result(slide.ID))
+			}
+
+			after, err := os.ReadDir(fallbackDir)
+			require.NoError(t, err)
+			assert.Equal(t, recordings, after)
+		})
+	}
+}
+
+func TestCommandManager_FallbackOff(t *testing.T) {
+	dir := t.TempDir()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	slide := types.Slide{ID: 1, ExecuteContent: []string{"echo failing", "exit 1"}}
+	path := newCommandManager(logger, types.Settings{Fallback: types.FallbackSettings{Dir: dir}}, nil).(*commandManager).fallbackPath(slide)
+	require.NoError(t, os.WriteFile(path, []byte(`{"version": 2}`+"\n"), 0o600))
+
+	cm := newCommandManager(logger, types.Settings{Fallback: types.FallbackSettings{Dir: dir, Replay: FallbackOff}}, nil)
+	ws, cleanup := setupWebSocket(t, cm)
+	defer cleanup()
+
+	require.NoError(t, cm.Run(slide, ""))
+	readTerminal(t, ws, "failing")
+	assert.Eventually(t, func() bool { return !cm.IsRunning() }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, types.CommandResult{}, cm.Result(slide.ID))
+}
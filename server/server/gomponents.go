@@ -1,6 +1,7 @@
 package server
 
 import (
+	"cmp"
 	"fmt"
 	"strings"
 
@@ -18,8 +19,13 @@ func gomponentsIfElse(condition bool, ifBranch, elseBranch gomponents.Node) gomp
 	return elseBranch
 }
 
+// hasTerminal checks whether a slide shows its content as commands above the terminal
+func hasTerminal(slideType types.SlideType) bool {
+	return slideType == types.SlideTypeCommand || slideType == types.SlideTypePlayback
+}
+
 func cleanedCommandGomponent(command string, slideType types.SlideType) gomponents.Node {
-	if slideType != types.SlideTypeCommand {
+	if !hasTerminal(slideType) {
 		return gomponents.Raw(strings.TrimSpace(command))
 	}
 
@@ -35,21 +41,55 @@ func cleanedCommandGomponent(command string, slideType types.SlideType) gomponen
 	return html.P(gomponents.Group(nodes))
 }
 
-func indexHTML(content gomponents.Node) gomponents.Node {
+const defaultTitle = "Backend Demo Tool"
+
+// staticScripts and staticStyles are the files in static that every page loads, in order
+var (
+	staticScripts = []string{"main.js", "highlight.js", "htmx.js", "xterm.js"}
+	staticStyles  = []string{"main.css", "xterm.css", "highlight.css"}
+)
+
+func indexHTML(settings types.Settings, acc access, content gomponents.Node) gomponents.Node {
+	var assets []gomponents.Node
+	for _, script := range staticScripts {
+		assets = append(assets, html.Script(html.Src("static/"+script)))
+	}
+	for _, style := range staticStyles {
+		assets = append(assets, html.Link(html.Rel("stylesheet"), html.Href("static/"+style)))
+	}
+	if strings.HasSuffix(settings.Theme, ".css") {
+		assets = append(assets, html.Link(html.Rel("stylesheet"), html.Href(settings.Theme)))
+	}
+
+	return pageHTML(settings, acc, gomponents.Group(assets), content)
+}
+
+// pageHTML is a page of the presentation that loads the scripts and styles in assets
+func pageHTML(settings types.Settings, acc access, assets gomponents.Node, content ...gomponents.Node) gomponents.Node {
+	title := settings.Title
+	if title == "" {
+		title = defaultTitle
+	}
+
+	theme := settings.Theme
+	isThemeFile := strings.HasSuffix(theme, ".css")
+
 	return html.HTML(
 		html.Head(
-			html.TitleEl(gomponents.Text("Backend Demo Tool")),
+			html.TitleEl(gomponents.Text(title)),
 			html.Meta(html.Name("viewport"), html.Content("width=device-width, initial-scale=1.0")),
-			html.Script(html.Src("static/main.js")),
-			html.Script(html.Src("static/highlight.js")),
-			html.Script(html.Src("static/htmx.js")),
-			html.Script(html.Src("static/xterm.js")),
-			html.Link(html.Rel("stylesheet"), html.Href("static/main.css")),
-			html.Link(html.Rel("stylesheet"), html.Href("static/xterm.css")),
-			html.Link(html.Rel("stylesheet"), html.Href("static/highlight.css")),
+			gomponents.If(acc.csrfToken != "", html.Meta(html.Name("csrf-token"), html.Content(acc.csrfToken))),
+			assets,
 		),
 		html.Body(
-			content,
+			gomponents.If(theme != "" && !isThemeFile, html.Class("theme-"+theme)),
+			gomponents.If(!acc.canExecute, html.DataAttr("read-only", "true")),
+			gomponents.If(settings.Replay, html.DataAttr("replay", "true")),
+			gomponents.If(acc.offline, html.DataAttr("offline", "true")),
+			gomponents.If(settings.Terminal.FontSize != 0, html.DataAttr("terminal-font-size", fmt.Sprint(settings.Terminal.FontSize))),
+			gomponents.If(settings.Terminal.FontFamily != "", html.DataAttr("terminal-font-family", settings.Terminal.FontFamily)),
+			gomponents.If(settings.Terminal.Scrollback != 0, html.DataAttr("terminal-scrollback", fmt.Sprint(settings.Terminal.Scrollback))),
+			gomponents.Group(content),
 		),
 	)
 }
@@ -62,13 +102,34 @@ func prevSlide(i, total int) int {
 	return ((i-1)%total + total) % total
 }
 
-func contentDiv(slideIdx, totalSlides int, slide types.Slide, isCmdRunning bool) gomponents.Node {
-	isCommand := slide.SlideType == types.SlideTypeCommand
+// slideLabel is how a slide is listed in the dropdown and the table of contents.
+func slideLabel(slideIdx, totalSlides int, title string) string {
+	if title == "" {
+		return fmt.Sprintf("Slide %d/%d", slideIdx+1, totalSlides)
+	}
+
+	return fmt.Sprintf("%d/%d: %v", slideIdx+1, totalSlides, title)
+}
+
+func terminalWrapper(visible bool) gomponents.Node {
+	return html.Div( // terminal always there but visibility controlled by CSS
+		html.ID("terminal-wrapper"),
+		gomponents.If(!visible, html.Class("hidden")),
+		html.Div(
+			html.ID("terminal"),
+			hx.Preserve("true"),
+		),
+	)
+}
+
+func contentDiv(slideIdx int, titles []string, slide types.Slide, isCmdRunning bool, acc access) gomponents.Node {
+	isCommand := hasTerminal(slide.SlideType)
+	totalSlides := len(titles)
 	return html.Div(
 		html.ID("command"),
 		html.Div(
 			html.ID("controls"),
-			slideSelect(slideIdx, totalSlides),
+			slideSelect(slideIdx, titles),
 			html.FormEl(
 				html.Class("control"),
 				hx.Get(fmt.Sprintf("/slides/%v", prevSlide(slideIdx, totalSlides))),
@@ -85,33 +146,170 @@ func contentDiv(slideIdx, totalSlides int, slide types.Slide, isCmdRunning bool)
 				hx.Trigger("click, keyup[key=='ArrowRight'] from:body"),
 				html.Button(gomponents.Text("next")),
 			),
-			gomponents.If(isCommand, runningButton(slide.ID, isCmdRunning)),
+			// the contents and overview are rendered by the server
+			gomponents.If(!acc.offline, gomponents.Group([]gomponents.Node{
+				html.FormEl(
+					html.Class("control"),
+					hx.Get(fmt.Sprintf("/contents?from=%v", slideIdx)),
+					hx.Swap("outerHTML"),
+					hx.Target("#command"),
+					hx.Trigger("click, keyup[key=='/'] from:body"),
+					html.Button(gomponents.Text("contents")),
+				),
+				html.FormEl(
+					html.Class("control"),
+					hx.Get(fmt.Sprintf("/overview?from=%v", slideIdx)),
+					hx.Swap("outerHTML"),
+					hx.Target("#command"),
+					hx.Trigger("click, keyup[key=='o'] from:body"),
+					html.Button(gomponents.Text("overview")),
+				),
+			})),
+			gomponents.If(isCommand && !acc.offline, runningButton(slide.ID, isCmdRunning, types.CommandResult{}, executeLabel(slide, 0), acc)),
+			gomponents.If(isCommand && acc.offline, offlineButton(slide)),
+			gomponents.If(
+				slide.SlideType == types.SlideTypePlayback && acc.canExecute,
+				playbackControls(slide.ID, types.PlaybackState{Speed: cmp.Or(slide.Playback.Speed, 1)}, acc.csrfToken),
+			),
+		),
+		html.Div(
+			html.ID("slide-content"),
+			slideBody(slide),
+			terminalWrapper(isCommand),
+		),
+	)
+}
+
+func slideBody(slide types.Slide) gomponents.Node {
+	return html.Div(
+		gomponentsIfElse(
+			hasTerminal(slide.SlideType),
+			html.Class("command-string"),
+			html.Class("text-string"),
+		),
+		cleanedCommandGomponent(slide.Content, slide.SlideType),
+	)
+}
+
+// overviewDiv renders every slide as a card in a grid, command slides also show the end of their last output.
+func overviewDiv(fromIdx int, slides []types.Slide, outputs map[int]string) gomponents.Node {
+	var cards []gomponents.Node
+	for _, slide := range slides {
+		cards = append(cards, overviewCard(slide, len(slides), slide.ID == fromIdx, outputs[slide.ID]))
+	}
+
+	return html.Div(
+		html.ID("command"),
+		html.Div(
+			html.ID("controls"),
+			html.FormEl(
+				html.Class("control"),
+				hx.Get(fmt.Sprintf("/slides/%v", fromIdx)),
+				hx.Swap("outerHTML"),
+				hx.Target("#command"),
+				hx.Trigger("click, keyup[key=='Escape' || key=='o'] from:body"),
+				html.Button(gomponents.Text("back")),
+			),
 		),
 		html.Div(
 			html.ID("slide-content"),
 			html.Div(
-				gomponentsIfElse(
-					isCommand,
-					html.Class("command-string"),
-					html.Class("text-string"),
-				),
-				cleanedCommandGomponent(slide.Content, slide.SlideType),
+				html.ID("overview"),
+				gomponents.Group(cards),
 			),
-			html.Div( // terminal always there but visibility controlled by CSS
-				html.ID("terminal-wrapper"),
-				gomponents.If(!isCommand, html.Class("hidden")),
-				html.Div(
-					html.ID("terminal"),
-					hx.Preserve("true"),
-				),
+			terminalWrapper(false),
+		),
+	)
+}
+
+func overviewCard(slide types.Slide, totalSlides int, isCurrent bool, output string) gomponents.Node {
+	// a grid of embedded pages and videos would all load and play at once
+	if !hasTerminal(slide.SlideType) {
+		slide.Content = embedPlaceholders(slide.Content)
+	}
+
+	return html.Div(
+		gomponentsIfElse(isCurrent, html.Class("overview-card current"), html.Class("overview-card")),
+		hx.Get(fmt.Sprintf("/slides/%v", slide.ID)),
+		hx.Swap("outerHTML"),
+		hx.Target("#command"),
+		html.Div(html.Class("overview-card-title"), gomponents.Text(slideLabel(slide.ID, totalSlides, slide.Title))),
+		html.Div(
+			html.Class("overview-card-body"),
+			slideBody(slide),
+			gomponents.If(output != "", html.Pre(html.Class("overview-output"), gomponents.Text(output))),
+		),
+	)
+}
+
+// contentsDiv is the searchable table of contents, it replaces the current slide until one is picked.
+func contentsDiv(fromIdx int, titles []string, results []types.Slide, commands bool) gomponents.Node {
+	return html.Div(
+		html.ID("command"),
+		html.Div(
+			html.ID("controls"),
+			html.FormEl(
+				html.Class("control"),
+				hx.Get(fmt.Sprintf("/slides/%v", fromIdx)),
+				hx.Swap("outerHTML"),
+				hx.Target("#command"),
+				hx.Trigger("click, keyup[key=='Escape'] from:body"),
+				html.Button(gomponents.Text("back")),
 			),
+			html.Input(
+				html.ID("contents-search"),
+				html.Type("search"),
+				html.Name("q"),
+				html.Placeholder("Search slides and commands"),
+				html.AutoFocus(),
+				hx.Get("/contents/search"),
+				hx.Trigger("input changed delay:200ms, search"),
+				hx.Target("#contents-results"),
+				hx.Swap("outerHTML"),
+			),
+		),
+		html.Div(
+			html.ID("slide-content"),
+			contentsResults(len(titles), results, commands),
+			terminalWrapper(false),
 		),
 	)
 }
 
-func slideSelect(slideIdx, totalSlides int) gomponents.Node {
+// contentsResults lists the slides found in the table of contents. Command slides show every line they run with
+// commands, otherwise only the lines shown on the slide.
+func contentsResults(totalSlides int, results []types.Slide, commands bool) gomponents.Node {
+	var items []gomponents.Node
+	for _, slide := range results {
+		snippet := truncate(plainText(slide.Content), 2*maxTitleLength)
+		if slide.SlideType == types.SlideTypeCommand {
+			lines := strings.Split(slide.Content, "\n")
+			if commands {
+				lines = slide.Commands
+			}
+			snippet = truncate("$ "+strings.Join(lines, "; "), 2*maxTitleLength)
+		}
+
+		items = append(items, html.Li(
+			html.Class("contents-result"),
+			hx.Get(fmt.Sprintf("/slides/%v", slide.ID)),
+			hx.Swap("outerHTML"),
+			hx.Target("#command"),
+			html.Span(html.Class("contents-title"), gomponents.Text(slideLabel(slide.ID, totalSlides, slide.Title))),
+			gomponents.If(snippet != slide.Title, html.Span(html.Class("contents-snippet"), gomponents.Text(snippet))),
+		))
+	}
+
+	return html.Ul(
+		html.ID("contents-results"),
+		gomponents.If(len(items) == 0, html.Li(html.Class("contents-empty"), gomponents.Text("No matching slides"))),
+		gomponents.Group(items),
+	)
+}
+
+func slideSelect(slideIdx int, titles []string) gomponents.Node {
 	var options []gomponents.Node
-	for i := range totalSlides {
+	for i, title := range titles {
 		options = append(options, html.Option(
 			gomponents.Group([]gomponents.Node{
 				html.Value(fmt.Sprint(i)),
@@ -120,7 +318,7 @@ func slideSelect(slideIdx, totalSlides int) gomponents.Node {
 					html.Selected(),
 				),
 			}),
-			gomponents.Text(fmt.Sprintf("Slide %d/%d", i+1, totalSlides)),
+			gomponents.Text(slideLabel(i, len(titles), title)),
 		))
 	}
 
@@ -133,7 +331,15 @@ func slideSelect(slideIdx, totalSlides int) gomponents.Node {
 	)
 }
 
-func runningButton(idx int, isCmdRunning bool) gomponents.Node {
+// runningButton shows the button to run or stop the command, along with how its last run ended
+func runningButton(idx int, isCmdRunning bool, result types.CommandResult, label string, acc access) gomponents.Node {
+	if !acc.canExecute {
+		return html.Div(
+			html.ID("button-container"),
+			html.Span(html.Class("read-only"), gomponents.Text("view only")),
+		)
+	}
+
 	if isCmdRunning {
 		return html.Div(
 			html.ID("button-container"),
@@ -141,32 +347,112 @@ func runningButton(idx int, isCmdRunning bool) gomponents.Node {
 			hx.Trigger("every 100ms"),
 			hx.Target("#button-container"),
 			hx.Swap("outerHTML"),
-			stopButton(idx),
+			stopButton(idx, acc.csrfToken),
+		)
+	}
+
+	return html.Div(
+		html.ID("button-container"),
+		executeButton(idx, label, acc.csrfToken),
+		gomponents.If(result.CanReplay, replayButton(idx, acc.csrfToken)),
+		gomponents.If(result.LimitHit != "", html.Span(html.Class("limit-hit"), gomponents.Text(result.LimitHit))),
+		gomponents.If(result.Replayed, html.Span(html.Class("replayed"), gomponents.Text("replay of the last successful run"))),
+	)
+}
+
+// offlineButton plays the recorded output of a slide in an exported presentation, slides with recorded output are
+// playback slides
+func offlineButton(slide types.Slide) gomponents.Node {
+	if slide.SlideType != types.SlideTypePlayback {
+		return html.Div(
+			html.ID("button-container"),
+			html.Span(html.Class("read-only"), gomponents.Text("not recorded")),
 		)
 	}
 
 	return html.Div(
 		html.ID("button-container"),
-		executeButton(idx),
+		html.Div(
+			html.Class("action-button"),
+			html.Button(html.DataAttr("recording", fmt.Sprint(slide.ID)), gomponents.Text("play")),
+		),
+	)
+}
+
+// replayButton replays the output of the last successful run of a command that failed
+func replayButton(idx int, csrfToken string) gomponents.Node {
+	return html.FormEl(
+		html.Class("action-button"),
+		hx.Post(fmt.Sprintf("/commands/%v/replay", idx)),
+		hx.Target("#button-container"),
+		hx.Swap("outerHTML"),
+		hx.Trigger("click, keyup[key=='r'] from:body"),
+		csrfInput(csrfToken),
+		html.Button(gomponents.Text("replay")),
+	)
+}
+
+// playbackControls are the buttons to pause, change the speed of or skip the pauses in the recording of a playback slide
+func playbackControls(idx int, state types.PlaybackState, csrfToken string) gomponents.Node {
+	pause := "pause"
+	if state.Paused {
+		pause = "resume"
+	}
+
+	return html.Div(
+		html.ID("playback-controls"),
+		playbackButton(idx, PlaybackPause, pause, "p", csrfToken),
+		playbackButton(idx, PlaybackSlower, "slower", "-", csrfToken),
+		html.Span(html.Class("playback-speed"), gomponents.Textf("%vx", state.Speed)),
+		playbackButton(idx, PlaybackFaster, "faster", "+", csrfToken),
+		playbackButton(idx, PlaybackSkip, "skip", "s", csrfToken),
+	)
+}
+
+func playbackButton(idx int, action, label, key, csrfToken string) gomponents.Node {
+	return html.FormEl(
+		html.Class("action-button"),
+		hx.Post(fmt.Sprintf("/commands/%v/playback", idx)),
+		hx.Target("#playback-controls"),
+		hx.Swap("outerHTML"),
+		hx.Trigger(fmt.Sprintf("click, keyup[key=='%v'] from:body", key)),
+		csrfInput(csrfToken),
+		html.Input(html.Type("hidden"), html.Name("action"), html.Value(action)),
+		html.Button(gomponents.Text(label)),
 	)
 }
 
-func stopButton(idx int) gomponents.Node {
+func csrfInput(csrfToken string) gomponents.Node {
+	return gomponents.If(csrfToken != "", html.Input(html.Type("hidden"), html.Name(csrfFieldName), html.Value(csrfToken)))
+}
+
+func stopButton(idx int, csrfToken string) gomponents.Node {
 	return html.FormEl(
 		html.Class("action-button"),
 		hx.Post(fmt.Sprintf("/commands/%v/stop", idx)),
 		hx.Target("#button-container"),
 		hx.Trigger("click, keyup[key==' '] from:body"),
+		csrfInput(csrfToken),
 		html.Button(gomponents.Text("stop")),
 	)
 }
 
-func executeButton(idx int) gomponents.Node {
+// executeLabel is the text of the button that runs a slide's command, step-through slides show the step that runs next
+func executeLabel(slide types.Slide, nextStep int) string {
+	if !slide.Steps {
+		return "execute"
+	}
+
+	return fmt.Sprintf("step %v/%v", nextStep+1, len(slide.StepCommands))
+}
+
+func executeButton(idx int, label string, csrfToken string) gomponents.Node {
 	return html.FormEl(
 		html.Class("action-button"),
 		hx.Post(fmt.Sprintf("/commands/%v/start", idx)),
 		hx.Target("#button-container"),
 		hx.Trigger("click, keyup[key==' '] from:body"),
-		html.Button(gomponents.Text("execute")),
+		csrfInput(csrfToken),
+		html.Button(gomponents.Text(label)),
 	)
 }
@@ -25,45 +25,183 @@ func TestContentDiv(t *testing.T) {
 	t.Run("plain slide", func(t *testing.T) {
 		testSlide := types.Slide{ID: 0, Content: "<p>this is a some text</p>", SlideType: types.SlideTypePlain}
 		var actual strings.Builder
-		err := contentDiv(3, 10, testSlide, false).Render(&actual)
+		err := contentDiv(3, make([]string, 10), testSlide, false, access{canExecute: true}).Render(&actual)
 		require.NoError(t, err)
-		expected := `<div id="command"><div id="controls"><select hx-get="/slides" hx-swap="outerHTML" hx-target="#command" name="idx"><option value="0">Slide 1/10</option><option value="1">Slide 2/10</option><option value="2">Slide 3/10</option><option value="3" selected>Slide 4/10</option><option value="4">Slide 5/10</option><option value="5">Slide 6/10</option><option value="6">Slide 7/10</option><option value="7">Slide 8/10</option><option value="8">Slide 9/10</option><option value="9">Slide 10/10</option></select><form class="control" hx-get="/slides/2" hx-swap="outerHTML" hx-target="#command" hx-trigger="click, keyup[key==&#39;ArrowLeft&#39;] from:body"><button>prev</button></form><form class="control" hx-get="/slides/4" hx-swap="outerHTML" hx-target="#command" hx-trigger="click, keyup[key==&#39;ArrowRight&#39;] from:body"><button>next</button></form></div><div id="slide-content"><div class="text-string"><p>this is a some text</p></div><div id="terminal-wrapper" class="hidden"><div id="terminal" hx-preserve="true"></div></div></div></div>`
+		expected := `<div id="command"><div id="controls"><select hx-get="/slides" hx-swap="outerHTML" hx-target="#command" name="idx"><option value="0">Slide 1/10</option><option value="1">Slide 2/10</option><option value="2">Slide 3/10</option><option value="3" selected>Slide 4/10</option><option value="4">Slide 5/10</option><option value="5">Slide 6/10</option><option value="6">Slide 7/10</option><option value="7">Slide 8/10</option><option value="8">Slide 9/10</option><option value="9">Slide 10/10</option></select><form class="control" hx-get="/slides/2" hx-swap="outerHTML" hx-target="#command" hx-trigger="click, keyup[key==&#39;ArrowLeft&#39;] from:body"><button>prev</button></form><form class="control" hx-get="/slides/4" hx-swap="outerHTML" hx-target="#command" hx-trigger="click, keyup[key==&#39;ArrowRight&#39;] from:body"><button>next</button></form><form class="control" hx-get="/contents?from=3" hx-swap="outerHTML" hx-target="#command" hx-trigger="click, keyup[key==&#39;/&#39;] from:body"><button>contents</button></form><form class="control" hx-get="/overview?from=3" hx-swap="outerHTML" hx-target="#command" hx-trigger="click, keyup[key==&#39;o&#39;] from:body"><button>overview</button></form></div><div id="slide-content"><div class="text-string"><p>this is a some text</p></div><div id="terminal-wrapper" class="hidden"><div id="terminal" hx-preserve="true"></div></div></div></div>`
 		assert.Equal(t, expected, actual.String())
 	})
 
 	t.Run("code slide", func(t *testing.T) {
 		testSlide := types.Slide{ID: 0, Content: "<pre><code>this is some code</code></pre>", SlideType: types.SlideTypeCodeblock}
 		var actual strings.Builder
-		err := contentDiv(3, 10, testSlide, false).Render(&actual)
+		err := contentDiv(3, make([]string, 10), testSlide, false, access{canExecute: true}).Render(&actual)
 		require.NoError(t, err)
-		expected := `<div id="command"><div id="controls"><select hx-get="/slides" hx-swap="outerHTML" hx-target="#command" name="idx"><option value="0">Slide 1/10</option><option value="1">Slide 2/10</option><option value="2">Slide 3/10</option><option value="3" selected>Slide 4/10</option><option value="4">Slide 5/10</option><option value="5">Slide 6/10</option><option value="6">Slide 7/10</option><option value="7">Slide 8/10</option><option value="8">Slide 9/10</option><option value="9">Slide 10/10</option></select><form class="control" hx-get="/slides/2" hx-swap="outerHTML" hx-target="#command" hx-trigger="click, keyup[key==&#39;ArrowLeft&#39;] from:body"><button>prev</button></form><form class="control" hx-get="/slides/4" hx-swap="outerHTML" hx-target="#command" hx-trigger="click, keyup[key==&#39;ArrowRight&#39;] from:body"><button>next</button></form></div><div id="slide-content"><div class="text-string"><pre><code>this is some code</code></pre></div><div id="terminal-wrapper" class="hidden"><div id="terminal" hx-preserve="true"></div></div></div></div>`
+		expected := `<div id="command"><div id="controls"><select hx-get="/slides" hx-swap="outerHTML" hx-target="#command" name="idx"><option value="0">Slide 1/10</option><option value="1">Slide 2/10</option><option value="2">Slide 3/10</option><option value="3" selected>Slide 4/10</option><option value="4">Slide 5/10</option><option value="5">Slide 6/10</option><option value="6">Slide 7/10</option><option value="7">Slide 8/10</option><option value="8">Slide 9/10</option><option value="9">Slide 10/10</option></select><form class="control" hx-get="/slides/2" hx-swap="outerHTML" hx-target="#command" hx-trigger="click, keyup[key==&#39;ArrowLeft&#39;] from:body"><button>prev</button></form><form class="control" hx-get="/slides/4" hx-swap="outerHTML" hx-target="#command" hx-trigger="click, keyup[key==&#39;ArrowRight&#39;] from:body"><button>next</button></form><form class="control" hx-get="/contents?from=3" hx-swap="outerHTML" hx-target="#command" hx-trigger="click, keyup[key==&#39;/&#39;] from:body"><button>contents</button></form><form class="control" hx-get="/overview?from=3" hx-swap="outerHTML" hx-target="#command" hx-trigger="click, keyup[key==&#39;o&#39;] from:body"><button>overview</button></form></div><div id="slide-content"><div class="text-string"><pre><code>this is some code</code></pre></div><div id="terminal-wrapper" class="hidden"><div id="terminal" hx-preserve="true"></div></div></div></div>`
 		assert.Equal(t, expected, actual.String())
 	})
 
 	t.Run("command slide", func(t *testing.T) {
 		testSlide := types.Slide{ID: 0, Content: "echo hello world", SlideType: types.SlideTypeCommand}
 		var actual strings.Builder
-		err := contentDiv(3, 10, testSlide, false).Render(&actual)
+		err := contentDiv(3, make([]string, 10), testSlide, false, access{canExecute: true}).Render(&actual)
 		require.NoError(t, err)
-		expected := `<div id="command"><div id="controls"><select hx-get="/slides" hx-swap="outerHTML" hx-target="#command" name="idx"><option value="0">Slide 1/10</option><option value="1">Slide 2/10</option><option value="2">Slide 3/10</option><option value="3" selected>Slide 4/10</option><option value="4">Slide 5/10</option><option value="5">Slide 6/10</option><option value="6">Slide 7/10</option><option value="7">Slide 8/10</option><option value="8">Slide 9/10</option><option value="9">Slide 10/10</option></select><form class="control" hx-get="/slides/2" hx-swap="outerHTML" hx-target="#command" hx-trigger="click, keyup[key==&#39;ArrowLeft&#39;] from:body"><button>prev</button></form><form class="control" hx-get="/slides/4" hx-swap="outerHTML" hx-target="#command" hx-trigger="click, keyup[key==&#39;ArrowRight&#39;] from:body"><button>next</button></form><div id="button-container"><form class="action-button" hx-post="/commands/0/start" hx-target="#button-container" hx-trigger="click, keyup[key==&#39; &#39;] from:body"><button>execute</button></form></div></div><div id="slide-content"><div class="command-string"><p>echo hello world</p></div><div id="terminal-wrapper"><div id="terminal" hx-preserve="true"></div></div></div></div>`
+		expected := `<div id="command"><div id="controls"><select hx-get="/slides" hx-swap="outerHTML" hx-target="#command" name="idx"><option value="0">Slide 1/10</option><option value="1">Slide 2/10</option><option value="2">Slide 3/10</option><option value="3" selected>Slide 4/10</option><option value="4">Slide 5/10</option><option value="5">Slide 6/10</option><option value="6">Slide 7/10</option><option value="7">Slide 8/10</option><option value="8">Slide 9/10</option><option value="9">Slide 10/10</option></select><form class="control" hx-get="/slides/2" hx-swap="outerHTML" hx-target="#command" hx-trigger="click, keyup[key==&#39;ArrowLeft&#39;] from:body"><button>prev</button></form><form class="control" hx-get="/slides/4" hx-swap="outerHTML" hx-target="#command" hx-trigger="click, keyup[key==&#39;ArrowRight&#39;] from:body"><button>next</button></form><form class="control" hx-get="/contents?from=3" hx-swap="outerHTML" hx-target="#command" hx-trigger="click, keyup[key==&#39;/&#39;] from:body"><button>contents</button></form><form class="control" hx-get="/overview?from=3" hx-swap="outerHTML" hx-target="#command" hx-trigger="click, keyup[key==&#39;o&#39;] from:body"><button>overview</button></form><div id="button-container"><form class="action-button" hx-post="/commands/0/start" hx-target="#button-container" hx-trigger="click, keyup[key==&#39; &#39;] from:body"><button>execute</button></form></div></div><div id="slide-content"><div class="command-string"><p>echo hello world</p></div><div id="terminal-wrapper"><div id="terminal" hx-preserve="true"></div></div></div></div>`
 		assert.Equal(t, expected, actual.String())
 	})
 
 	t.Run("multi-line command slide", func(t *testing.T) {
 		testSlide := types.Slide{ID: 0, Content: "echo line1\necho line2", SlideType: types.SlideTypeCommand}
 		var actual strings.Builder
-		err := contentDiv(3, 10, testSlide, false).Render(&actual)
+		err := contentDiv(3, make([]string, 10), testSlide, false, access{canExecute: true}).Render(&actual)
 		require.NoError(t, err)
-		expected := `<div id="command"><div id="controls"><select hx-get="/slides" hx-swap="outerHTML" hx-target="#command" name="idx"><option value="0">Slide 1/10</option><option value="1">Slide 2/10</option><option value="2">Slide 3/10</option><option value="3" selected>Slide 4/10</option><option value="4">Slide 5/10</option><option value="5">Slide 6/10</option><option value="6">Slide 7/10</option><option value="7">Slide 8/10</option><option value="8">Slide 9/10</option><option value="9">Slide 10/10</option></select><form class="control" hx-get="/slides/2" hx-swap="outerHTML" hx-target="#command" hx-trigger="click, keyup[key==&#39;ArrowLeft&#39;] from:body"><button>prev</button></form><form class="control" hx-get="/slides/4" hx-swap="outerHTML" hx-target="#command" hx-trigger="click, keyup[key==&#39;ArrowRight&#39;] from:body"><button>next</button></form><div id="button-container"><form class="action-button" hx-post="/commands/0/start" hx-target="#button-container" hx-trigger="click, keyup[key==&#39; &#39;] from:body"><button>execute</button></form></div></div><div id="slide-content"><div class="command-string"><p>echo line1<br>echo line2</p></div><div id="terminal-wrapper"><div id="terminal" hx-preserve="true"></div></div></div></div>`
+		expected := `<div id="command"><div id="controls"><select hx-get="/slides" hx-swap="outerHTML" hx-target="#command" name="idx"><option value="0">Slide 1/10</option><option value="1">Slide 2/10</option><option value="2">Slide 3/10</option><option value="3" selected>Slide 4/10</option><option value="4">Slide 5/10</option><option value="5">Slide 6/10</option><option value="6">Slide 7/10</option><option value="7">Slide 8/10</option><option value="8">Slide 9/10</option><option value="9">Slide 10/10</option></select><form class="control" hx-get="/slides/2" hx-swap="outerHTML" hx-target="#command" hx-trigger="click, keyup[key==&#39;ArrowLeft&#39;] from:body"><button>prev</button></form><form class="control" hx-get="/slides/4" hx-swap="outerHTML" hx-target="#command" hx-trigger="click, keyup[key==&#39;ArrowRight&#39;] from:body"><button>next</button></form><form class="control" hx-get="/contents?from=3" hx-swap="outerHTML" hx-target="#command" hx-trigger="click, keyup[key==&#39;/&#39;] from:body"><button>contents</button></form><form class="control" hx-get="/overview?from=3" hx-swap="outerHTML" hx-target="#command" hx-trigger="click, keyup[key==&#39;o&#39;] from:body"><button>overview</button></form><div id="button-container"><form class="action-button" hx-post="/commands/0/start" hx-target="#button-container" hx-trigger="click, keyup[key==&#39; &#39;] from:body"><button>execute</button></form></div></div><div id="slide-content"><div class="command-string"><p>echo line1<br>echo line2</p></div><div id="terminal-wrapper"><div id="terminal" hx-preserve="true"></div></div></div></div>`
 		assert.Equal(t, expected, actual.String())
 	})
 }
 
-func TestIndex(t *testing.T) {
-	content := html.Div()
+func TestSlideSelect(t *testing.T) {
 	var actual strings.Builder
-	err := indexHTML(content).Render(&actual)
+	err := slideSelect(1, []string{"Intro", "", "echo hello"}).Render(&actual)
 	require.NoError(t, err)
-	expected := `<html><head><title>Backend Demo Tool</title><meta name="viewport" content="width=device-width, initial-scale=1.0"><script src="static/main.js"></script><script src="static/highlight.js"></script><script src="static/htmx.js"></script><script src="static/xterm.js"></script><link rel="stylesheet" href="static/main.css"><link rel="stylesheet" href="static/xterm.css"><link rel="stylesheet" href="static/highlight.css"></head><body><div></div></body></html>`
+	expected := `<select hx-get="/slides" hx-swap="outerHTML" hx-target="#command" name="idx"><option value="0">1/3: Intro</option><option value="1" selected>Slide 2/3</option><option value="2">3/3: echo hello</option></select>`
 	assert.Equal(t, expected, actual.String())
 }
+
+func TestContentsResults(t *testing.T) {
+	t.Run("matching slides", func(t *testing.T) {
+		results := []types.Slide{
+			{ID: 0, Title: "Intro", Content: "<h1>Intro</h1>", SlideType: types.SlideTypePlain},
+			{ID: 2, Title: "echo hello", Content: "echo hello", Commands: []string{"cd /tmp", "echo hello"}, SlideType: types.SlideTypeCommand},
+		}
+		var actual strings.Builder
+		err := contentsResults(3, results, true).Render(&actual)
+		require.NoError(t, err)
+		expected := `<ul id="contents-results"><li class="contents-result" hx-get="/slides/0" hx-swap="outerHTML" hx-target="#command"><span class="contents-title">1/3: Intro</span></li><li class="contents-result" hx-get="/slides/2" hx-swap="outerHTML" hx-target="#command"><span class="contents-title">3/3: echo hello</span><span class="contents-snippet">$ cd /tmp; echo hello</span></li></ul>`
+		assert.Equal(t, expected, actual.String())
+	})
+
+	t.Run("viewer", func(t *testing.T) {
+		results := []types.Slide{{ID: 2, Title: "Say hello", Content: "echo hello", Commands: []string{"cd /tmp", "echo hello"}, SlideType: types.SlideTypeCommand}}
+		var actual strings.Builder
+		err := contentsResults(3, results, false).Render(&actual)
+		require.NoError(t, err)
+		assert.Contains(t, actual.String(), `<span class="contents-snippet">$ echo hello</span>`)
+		assert.NotContains(t, actual.String(), "cd /tmp")
+	})
+
+	t.Run("no matches", func(t *testing.T) {
+		var actual strings.Builder
+		err := contentsResults(3, nil, true).Render(&actual)
+		require.NoError(t, err)
+		assert.Equal(t, `<ul id="contents-results"><li class="contents-empty">No matching slides</li></ul>`, actual.String())
+	})
+}
+
+func TestOverviewCard(t *testing.T) {
+	t.Run("text slide", func(t *testing.T) {
+		testSlide := types.Slide{ID: 1, Title: "Intro", Content: "<h1>Intro</h1>", SlideType: types.SlideTypePlain}
+		var actual strings.Builder
+		err := overviewCard(testSlide, 3, false, "").Render(&actual)
+		require.NoError(t, err)
+		expected := `<div class="overview-card" hx-get="/slides/1" hx-swap="outerHTML" hx-target="#command"><div class="overview-card-title">2/3: Intro</div><div class="overview-card-body"><div class="text-string"><h1>Intro</h1></div></div></div>`
+		assert.Equal(t, expected, actual.String())
+	})
+
+	t.Run("embeds", func(t *testing.T) {
+		testSlide := types.Slide{ID: 0, Title: "Demo", Content: `<p>Demo</p><iframe src="https://example.com"></iframe><video autoplay src="demo.mp4"><source src="demo.webm">fallback</video><img src="logo.png">`, SlideType: types.SlideTypePlain}
+		var actual strings.Builder
+		err := overviewCard(testSlide, 3, false, "").Render(&actual)
+		require.NoError(t, err)
+		assert.Contains(t, actual.String(), `<p>Demo</p><span class="embed-placeholder">iframe</span><span class="embed-placeholder">video</span><img src="logo.png">`)
+		assert.NotContains(t, actual.String(), "example.com")
+		assert.NotContains(t, actual.String(), "demo.")
+	})
+
+	t.Run("current command slide with output", func(t *testing.T) {
+		testSlide := types.Slide{ID: 2, Title: "echo hello", Content: "echo hello", SlideType: types.SlideTypeCommand}
+		var actual strings.Builder
+		err := overviewCard(testSlide, 3, true, "hello").Render(&actual)
+		require.NoError(t, err)
+		expected := `<div class="overview-card current" hx-get="/slides/2" hx-swap="outerHTML" hx-target="#command"><div class="overview-card-title">3/3: echo hello</div><div class="overview-card-body"><div class="command-string"><p>echo hello</p></div><pre class="overview-output">hello</pre></div></div>`
+		assert.Equal(t, expected, actual.String())
+	})
+}
+
+func TestRunningButton(t *testing.T) {
+	t.Run("execute", func(t *testing.T) {
+		var actual strings.Builder
+		err := runningButton(2, false, types.CommandResult{}, "execute", access{canExecute: true, csrfToken: "abc"}).Render(&actual)
+		require.NoError(t, err)
+		expected := `<div id="button-container"><form class="action-button" hx-post="/commands/2/start" hx-target="#button-container" hx-trigger="click, keyup[key==&#39; &#39;] from:body"><input type="hidden" name="csrf_token" value="abc"><button>execute</button></form></div>`
+		assert.Equal(t, expected, actual.String())
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		var actual strings.Builder
+		err := runningButton(2, true, types.CommandResult{}, "", access{canExecute: true, csrfToken: "abc"}).Render(&actual)
+		require.NoError(t, err)
+		expected := `<div id="button-container" hx-get="/commands/2/status" hx-trigger="every 100ms" hx-target="#button-container" hx-swap="outerHTML"><form class="action-button" hx-post="/commands/2/stop" hx-target="#button-container" hx-trigger="click, keyup[key==&#39; &#39;] from:body"><input type="hidden" name="csrf_token" value="abc"><button>stop</button></form></div>`
+		assert.Equal(t, expected, actual.String())
+	})
+
+	t.Run("view only", func(t *testing.T) {
+		var actual strings.Builder
+		err := runningButton(2, true, types.CommandResult{}, "", access{}).Render(&actual)
+		require.NoError(t, err)
+		assert.Equal(t, `<div id="button-container"><span class="read-only">view only</span></div>`, actual.String())
+	})
+}
+
+func TestExecuteLabel(t *testing.T) {
+	steps := []types.Step{{Command: "ls"}, {Hidden: []string{"cd /tmp"}, Command: "pwd"}}
+	assert.Equal(t, "execute", executeLabel(types.Slide{Commands: []string{"ls", "cd /tmp", "pwd"}}, 0))
+	assert.Equal(t, "step 1/2", executeLabel(types.Slide{Steps: true, StepCommands: steps}, 0))
+	assert.Equal(t, "step 2/2", executeLabel(types.Slide{Steps: true, StepCommands: steps}, 1))
+}
+
+func TestIndex(t *testing.T) {
+	t.Run("default settings", func(t *testing.T) {
+		content := html.Div()
+		var actual strings.Builder
+		err := indexHTML(types.Settings{}, access{canExecute: true}, content).Render(&actual)
+		require.NoError(t, err)
+		expected := `<html><head><title>Backend Demo Tool</title><meta name="viewport" content="width=device-width, initial-scale=1.0"><script src="static/main.js"></script><script src="static/highlight.js"></script><script src="static/htmx.js"></script><script src="static/xterm.js"></script><link rel="stylesheet" href="static/main.css"><link rel="stylesheet" href="static/xterm.css"><link rel="stylesheet" href="static/highlight.css"></head><body><div></div></body></html>`
+		assert.Equal(t, expected, actual.String())
+	})
+
+	t.Run("front matter settings", func(t *testing.T) {
+		settings := types.Settings{
+			Title:    "My Demo",
+			Theme:    "light",
+			Terminal: types.TerminalSettings{FontSize: 18, FontFamily: "monospace", Scrollback: 500},
+		}
+		var actual strings.Builder
+		err := indexHTML(settings, access{canExecute: true}, html.Div()).Render(&actual)
+		require.NoError(t, err)
+		assert.Contains(t, actual.String(), `<title>My Demo</title>`)
+		assert.Contains(t, actual.String(), `<body class="theme-light" data-terminal-font-size="18" data-terminal-font-family="monospace" data-terminal-scrollback="500">`)
+	})
+
+	t.Run("presenter session", func(t *testing.T) {
+		var actual strings.Builder
+		err := indexHTML(types.Settings{}, access{canExecute: true, csrfToken: "abc"}, html.Div()).Render(&actual)
+		require.NoError(t, err)
+		assert.Contains(t, actual.String(), `<meta name="csrf-token" content="abc">`)
+		assert.Contains(t, actual.String(), `<body><div></div></body>`)
+	})
+
+	t.Run("viewer", func(t *testing.T) {
+		var actual strings.Builder
+		err := indexHTML(types.Settings{}, access{}, html.Div()).Render(&actual)
+		require.NoError(t, err)
+		assert.NotContains(t, actual.String(), `csrf-token`)
+		assert.Contains(t, actual.String(), `<body data-read-only="true"><div></div></body>`)
+	})
+
+	t.Run("theme stylesheet", func(t *testing.T) {
+		var actual strings.Builder
+		err := indexHTML(types.Settings{Theme: "theme.css"}, access{canExecute: true}, html.Div()).Render(&actual)
+		require.NoError(t, err)
+		assert.Contains(t, actual.String(), `<link rel="stylesheet" href="theme.css"></head><body><div></div></body>`)
+	})
+}
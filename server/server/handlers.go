@@ -7,11 +7,29 @@ import (
 	"strconv"
 
 	"github.com/gorilla/websocket"
-)
 
-var upgrader = newUpgrader(false)
+	"github.com/joshjennings98/backend-demo/server/v2/types"
+)
 
 func (s *server) HandlerIndex(w http.ResponseWriter, r *http.Request) {
+	if s.auth != nil {
+		loggedIn, err := s.auth.login(w, r)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			s.logger.Error("could not start presenter session", "error", err.Error())
+			return
+		}
+
+		// drop the token from the URL so it doesn't end up in the browser history or on screen
+		if loggedIn {
+			s.logger.Info("presenter session started", "remote", r.RemoteAddr)
+			http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+			return
+		}
+	}
+
+	acc := s.access(r)
+
 	slide, err := s.GetSlide(0)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -19,7 +37,11 @@ func (s *server) HandlerIndex(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = indexHTML(contentDiv(0, s.GetSlideCount(), slide, false)).Render(w)
+	if acc.canExecute {
+		s.recordSession(SessionEventSlide, 0)
+	}
+
+	err = indexHTML(s.settings, acc, contentDiv(0, s.GetSlideTitles(), slide, false, acc)).Render(w)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		s.logger.Error("could not execute index handler", "error", err.Error())
@@ -57,11 +79,15 @@ func (s *server) HandlerSlideByIndex(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// stop running command and clear terminal when changing slides
-	_ = s.commandManager.Stop()
-	_ = s.commandManager.Clear()
+	// stop running command and clear terminal when the presenter changes slides
+	acc := s.access(r)
+	if acc.canExecute {
+		_ = s.commandManager.Stop()
+		_ = s.commandManager.Clear()
+		s.recordSession(SessionEventSlide, id)
+	}
 
-	err = contentDiv(id, s.GetSlideCount(), slide, false).Render(w)
+	err = contentDiv(id, s.GetSlideTitles(), slide, false, acc).Render(w)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		s.logger.Error("could not execute slide handler", "error", err.Error())
@@ -88,12 +114,16 @@ func (s *server) monitorWebsocket(ws *websocket.Conn) {
 
 func (s *server) HandlerWebSocket(w http.ResponseWriter, r *http.Request) {
 	s.logger.Info("websocket connection requested")
+	if !s.authorize(w, r) {
+		return
+	}
+
 	err := s.commandManager.CloseWebsocketConnection()
 	if err != nil {
 		s.logger.Warn("error closing existing websocket", "error", err.Error())
 	}
 
-	ws, err := upgrader.Upgrade(w, r, nil)
+	ws, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		s.logger.Error("couldn't upgrade to websocket", "error", err.Error())
 		return
@@ -106,6 +136,10 @@ func (s *server) HandlerWebSocket(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *server) HandlerCommandStart(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(w, r) {
+		return
+	}
+
 	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
@@ -125,14 +159,15 @@ func (s *server) HandlerCommandStart(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = runningButton(id, true).Render(w)
+	err = runningButton(id, true, types.CommandResult{}, "", s.access(r)).Render(w)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		s.logger.Error("could not render running button", "error", err.Error())
 		return
 	}
 
-	err = s.commandManager.Run(slide.ExecuteContent)
+	s.recordSession(SessionEventStart, id)
+	err = s.commandManager.Run(slide, r.RemoteAddr)
 	if err != nil {
 		s.logger.Error("could not start commands", "error", err.Error())
 	}
@@ -146,7 +181,7 @@ func (s *server) HandlerCommandStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = s.GetSlide(id)
+	slide, err := s.GetSlide(id)
 	if err != nil {
 		if errors.Is(err, ErrSlideIndexOutOfBounds) {
 			w.WriteHeader(http.StatusNotFound)
@@ -159,7 +194,12 @@ func (s *server) HandlerCommandStatus(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !s.commandManager.IsRunning() {
-		err = runningButton(id, false).Render(w)
+		nextStep := 0
+		if slide.Steps {
+			nextStep = s.commandManager.NextStep(id)
+		}
+
+		err = runningButton(id, false, s.commandManager.Result(id), executeLabel(slide, nextStep), s.access(r)).Render(w)
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			s.logger.Error("could not render running button in command status handler", "running", false, "error", err.Error())
@@ -173,6 +213,10 @@ func (s *server) HandlerCommandStatus(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *server) HandlerCommandStop(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(w, r) {
+		return
+	}
+
 	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
@@ -180,7 +224,7 @@ func (s *server) HandlerCommandStop(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = s.GetSlide(id)
+	slide, err := s.GetSlide(id)
 	if err != nil {
 		if errors.Is(err, ErrSlideIndexOutOfBounds) {
 			w.WriteHeader(http.StatusNotFound)
@@ -193,11 +237,168 @@ func (s *server) HandlerCommandStop(w http.ResponseWriter, r *http.Request) {
 	}
 
 	_ = s.commandManager.Stop()
+	s.recordSession(SessionEventStop, id)
 
-	err = runningButton(id, false).Render(w)
+	// stopping ends the steps of a step-through slide so they start again from the first line
+	err = runningButton(id, false, types.CommandResult{}, executeLabel(slide, 0), s.access(r)).Render(w)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		s.logger.Error("could not render running button in command stop", "error", err.Error())
 		return
 	}
 }
+
+func (s *server) HandlerCommandReplay(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(w, r) {
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		s.logger.Error("could not parse path parameter 'id' in command replay handler", "error", err.Error())
+		return
+	}
+
+	slide, err := s.GetSlide(id)
+	if err != nil {
+		if errors.Is(err, ErrSlideIndexOutOfBounds) {
+			w.WriteHeader(http.StatusNotFound)
+			s.logger.Warn("slide index out of bounds in command replay", "id", id)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			s.logger.Error("could not get slide in command replay", "id", id, "error", err.Error())
+		}
+		return
+	}
+
+	s.recordSession(SessionEventStart, id)
+	if err = s.commandManager.Replay(slide); err != nil {
+		if errors.Is(err, ErrNoFallback) {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		s.logger.Warn("could not replay command", "id", id, "error", err.Error())
+		return
+	}
+
+	err = runningButton(id, true, types.CommandResult{}, "", s.access(r)).Render(w)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		s.logger.Error("could not render running button in command replay", "error", err.Error())
+		return
+	}
+}
+
+func (s *server) HandlerPlayback(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(w, r) {
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		s.logger.Error("could not parse path parameter 'id' in playback handler", "error", err.Error())
+		return
+	}
+
+	slide, err := s.GetSlide(id)
+	if err != nil {
+		if errors.Is(err, ErrSlideIndexOutOfBounds) {
+			w.WriteHeader(http.StatusNotFound)
+			s.logger.Warn("slide index out of bounds in playback", "id", id)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			s.logger.Error("could not get slide in playback", "id", id, "error", err.Error())
+		}
+		return
+	}
+
+	if slide.SlideType != types.SlideTypePlayback {
+		w.WriteHeader(http.StatusBadRequest)
+		s.logger.Warn("slide is not a playback slide", "id", id)
+		return
+	}
+
+	state, err := s.commandManager.ControlPlayback(r.FormValue("action"))
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrNotPlaying):
+			w.WriteHeader(http.StatusConflict)
+		case errors.Is(err, ErrUnknownPlaybackAction):
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		s.logger.Warn("could not control playback", "id", id, "error", err.Error())
+		return
+	}
+
+	err = playbackControls(id, state, s.access(r).csrfToken).Render(w)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		s.logger.Error("could not render playback controls", "error", err.Error())
+		return
+	}
+}
+
+func (s *server) HandlerContents(w http.ResponseWriter, r *http.Request) {
+	from := 0
+	if fromIdx := r.URL.Query().Get("from"); fromIdx != "" {
+		var err error
+		from, err = strconv.Atoi(fromIdx)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			s.logger.Error("could not parse query parameter 'from' in contents handler", "error", err.Error())
+			return
+		}
+	}
+
+	commands := s.access(r).canExecute
+	err := contentsDiv(from, s.GetSlideTitles(), s.SearchSlides("", commands), commands).Render(w)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		s.logger.Error("could not execute contents handler", "error", err.Error())
+		return
+	}
+}
+
+func (s *server) HandlerOverview(w http.ResponseWriter, r *http.Request) {
+	from := 0
+	if fromIdx := r.URL.Query().Get("from"); fromIdx != "" {
+		var err error
+		from, err = strconv.Atoi(fromIdx)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			s.logger.Error("could not parse query parameter 'from' in overview handler", "error", err.Error())
+			return
+		}
+	}
+
+	// only the presenter can see the terminal
+	acc := s.access(r)
+	outputs := map[int]string{}
+	for _, slide := range s.slides {
+		if hasTerminal(slide.SlideType) && acc.canExecute {
+			outputs[slide.ID] = s.commandManager.LastOutput(slide.ID)
+		}
+	}
+
+	err := overviewDiv(from, s.slides, outputs).Render(w)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		s.logger.Error("could not execute overview handler", "error", err.Error())
+		return
+	}
+}
+
+func (s *server) HandlerSearch(w http.ResponseWriter, r *http.Request) {
+	commands := s.access(r).canExecute
+	err := contentsResults(s.GetSlideCount(), s.SearchSlides(r.URL.Query().Get("q"), commands), commands).Render(w)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		s.logger.Error("could not execute search handler", "error", err.Error())
+		return
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -132,7 +134,7 @@ func TestHandlerCommandStart(t *testing.T) {
 
 	cmdManager.
 		EXPECT().
-		Run(gomock.Any()).
+		Run(gomock.Any(), gomock.Any()).
 		Return(nil)
 
 	t.Run("Valid path parameter", func(t *testing.T) {
@@ -197,6 +199,11 @@ func TestHandlerCommandStatus(t *testing.T) {
 			IsRunning().
 			Return(false)
 
+		cmdManager.
+			EXPECT().
+			Result(1).
+			Return(types.CommandResult{})
+
 		mux := http.NewServeMux()
 		mux.HandleFunc("GET /commands/{id}/status", s.HandlerCommandStatus)
 
@@ -208,6 +215,52 @@ func TestHandlerCommandStatus(t *testing.T) {
 
 		assert.Equal(t, http.StatusOK, rr.Code)
 	})
+
+	t.Run("Replay offered", func(t *testing.T) {
+		s, cmdManager := setupServer(t)
+
+		cmdManager.
+			EXPECT().
+			IsRunning().
+			Return(false)
+
+		cmdManager.
+			EXPECT().
+			Result(1).
+			Return(types.CommandResult{CanReplay: true})
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /commands/{id}/status", s.HandlerCommandStatus)
+
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", "/commands/1/status", nil))
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), `hx-post="/commands/1/replay"`)
+	})
+
+	t.Run("Stopped by limit", func(t *testing.T) {
+		s, cmdManager := setupServer(t)
+
+		cmdManager.
+			EXPECT().
+			IsRunning().
+			Return(false)
+
+		cmdManager.
+			EXPECT().
+			Result(1).
+			Return(types.CommandResult{LimitHit: "timeout limit of 10s exceeded"})
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /commands/{id}/status", s.HandlerCommandStatus)
+
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", "/commands/1/status", nil))
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), `<span class="limit-hit">timeout limit of 10s exceeded</span>`)
+	})
 }
 
 func TestHandlerCommandStop(t *testing.T) {
@@ -241,3 +294,310 @@ func TestHandlerCommandStop(t *testing.T) {
 		assert.Equal(t, http.StatusBadRequest, rr.Code)
 	})
 }
+
+func TestHandlerCommandReplay(t *testing.T) {
+	s, cmdManager := setupServer(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /commands/{id}/replay", s.HandlerCommandReplay)
+
+	post := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("POST", path, nil))
+		return rr
+	}
+
+	t.Run("Replaying", func(t *testing.T) {
+		cmdManager.
+			EXPECT().
+			Replay(s.slides[1]).
+			Return(nil)
+
+		rr := post("/commands/1/replay")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), `hx-post="/commands/1/stop"`)
+	})
+
+	t.Run("Nothing to replay", func(t *testing.T) {
+		cmdManager.
+			EXPECT().
+			Replay(s.slides[1]).
+			Return(ErrNoFallback)
+
+		assert.Equal(t, http.StatusNotFound, post("/commands/1/replay").Code)
+	})
+
+	t.Run("Invalid path parameter", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, post("/commands/invalid/replay").Code)
+	})
+}
+
+func TestHandlerPlayback(t *testing.T) {
+	s, cmdManager := setupServer(t)
+	s.slides = append(s.slides, types.Slide{ID: 2, Content: "kubectl apply", Recording: "deploy.cast", SlideType: types.SlideTypePlayback})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /commands/{id}/playback", s.HandlerPlayback)
+
+	post := func(path, action string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("POST", path, strings.NewReader(url.Values{"action": {action}}.Encode()))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Playing", func(t *testing.T) {
+		cmdManager.
+			EXPECT().
+			ControlPlayback(PlaybackFaster).
+			Return(types.PlaybackState{Speed: 2}, nil)
+
+		rr := post("/commands/2/playback", PlaybackFaster)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), `<span class="playback-speed">2x</span>`)
+	})
+
+	t.Run("Not playing", func(t *testing.T) {
+		cmdManager.
+			EXPECT().
+			ControlPlayback(PlaybackPause).
+			Return(types.PlaybackState{}, ErrNotPlaying)
+
+		assert.Equal(t, http.StatusConflict, post("/commands/2/playback", PlaybackPause).Code)
+	})
+
+	t.Run("Not a playback slide", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, post("/commands/1/playback", PlaybackPause).Code)
+	})
+
+	t.Run("Invalid path parameter", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, post("/commands/invalid/playback", PlaybackPause).Code)
+	})
+}
+
+func TestHandlerContents(t *testing.T) {
+	s, _ := setupServer(t)
+
+	t.Run("Valid query parameter", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/contents?from=1", nil)
+		require.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(s.HandlerContents).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), `hx-get="/slides/1"`)
+	})
+
+	t.Run("Invalid query parameter", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/contents?from=invalid", nil)
+		require.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(s.HandlerContents).ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+func TestHandlerSearch(t *testing.T) {
+	s, _ := setupServer(t)
+
+	req, err := http.NewRequest("GET", "/contents/search?q=world", nil)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(s.HandlerSearch).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `hx-get="/slides/1"`)
+	assert.NotContains(t, rr.Body.String(), `hx-get="/slides/0"`)
+}
+
+func TestHandlerContents_Redaction(t *testing.T) {
+	s, _ := setupServer(t)
+	s.slides = nil
+
+	var err error
+	s.redactor, err = newRedactor(types.Settings{Redact: types.RedactSettings{Patterns: []string{`sk_live_\w+`}}})
+	require.NoError(t, err)
+	s.ParseSlide("$ export KEY=sk_live_abc123\n$ echo deploying")
+	s.ParseSlide("$ curl -u sk_live_zzz999 api")
+
+	get := func(target string) string {
+		rr := httptest.NewRecorder()
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /contents", s.HandlerContents)
+		mux.HandleFunc("GET /contents/search", s.HandlerSearch)
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		return rr.Body.String()
+	}
+
+	body := get("/contents")
+	assert.Contains(t, body, "$ export KEY=********; echo deploying")
+	assert.NotContains(t, body, "sk_live")
+
+	// secrets can't be found by searching for them
+	body = get("/contents/search?q=sk_live_zzz")
+	assert.Contains(t, body, "No matching slides")
+	assert.NotContains(t, body, "sk_live")
+}
+
+func TestHandlerOverview(t *testing.T) {
+	s, cmdManager := setupServer(t)
+
+	cmdManager.
+		EXPECT().
+		LastOutput(1).
+		Return("world")
+
+	req, err := http.NewRequest("GET", "/overview?from=1", nil)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(s.HandlerOverview).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `<div class="overview-card current" hx-get="/slides/1"`)
+	assert.Contains(t, rr.Body.String(), `<pre class="overview-output">world</pre>`)
+}
+
+func TestHandlers_Auth(t *testing.T) {
+	s, cmdManager := setupServer(t)
+
+	var err error
+	s.auth, err = newAuthenticator(false)
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /presentation", s.HandlerIndex)
+	mux.HandleFunc("GET /slides/{id}", s.HandlerSlideByIndex)
+	mux.HandleFunc("POST /commands/{id}/start", s.HandlerCommandStart)
+	mux.HandleFunc("POST /commands/{id}/stop", s.HandlerCommandStop)
+	mux.HandleFunc("GET /ws", s.HandlerWebSocket)
+	mux.HandleFunc("GET /contents", s.HandlerContents)
+	mux.HandleFunc("GET /contents/search", s.HandlerSearch)
+	mux.HandleFunc("GET /overview", s.HandlerOverview)
+
+	s.slides = append(s.slides, types.Slide{ID: 2, Content: "make deploy", ExecuteContent: []string{"export STAGE=hidden-stage", "make deploy"}, SlideType: types.SlideTypeCommand})
+
+	t.Run("Viewer is read only", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", "/presentation", nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), `data-read-only="true"`)
+
+		// changing slides as a viewer doesn't stop the presenter's command
+		rr = httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", "/slides/1", nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), "view only")
+
+		for _, path := range []string{"/commands/1/start", "/commands/1/stop"} {
+			rr = httptest.NewRecorder()
+			mux.ServeHTTP(rr, httptest.NewRequest("POST", path, nil))
+			assert.Equal(t, http.StatusForbidden, rr.Code, path)
+		}
+
+		rr = httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", "/ws", nil))
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("Viewer can't see the terminal or hidden lines", func(t *testing.T) {
+		// the mock command manager fails the test if the overview asks for the output of a command
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", "/overview", nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.NotContains(t, rr.Body.String(), "overview-output")
+
+		rr = httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", "/contents", nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), "make deploy")
+		assert.NotContains(t, rr.Body.String(), "hidden-stage")
+
+		rr = httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", "/contents/search?q=hidden-stage", nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), "No matching slides")
+	})
+
+	t.Run("Presenter logs in with token", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", "/presentation?token="+s.auth.token, nil))
+		assert.Equal(t, http.StatusSeeOther, rr.Code)
+		assert.Equal(t, "/presentation", rr.Header().Get("Location"))
+
+		cookies := rr.Result().Cookies() //nolint:bodyclose // recorder bodies don't need closing
+		require.Len(t, cookies, 1)
+
+		req := httptest.NewRequest("GET", "/presentation", nil)
+		req.AddCookie(cookies[0])
+		rr = httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), `<meta name="csrf-token"`)
+
+		csrfToken, ok := s.auth.csrfToken(req)
+		require.True(t, ok)
+
+		cmdManager.
+			EXPECT().
+			Run(gomock.Any(), gomock.Any()).
+			Return(nil)
+
+		req = httptest.NewRequest("POST", "/commands/1/start", nil)
+		req.Header.Set(csrfHeaderName, csrfToken)
+		req.AddCookie(cookies[0])
+		rr = httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), `name="csrf_token" value="`+csrfToken+`"`)
+
+		// a session without the CSRF token is rejected
+		req = httptest.NewRequest("POST", "/commands/1/stop", nil)
+		req.AddCookie(cookies[0])
+		rr = httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+}
+
+func TestHandlers_ExecutionDisabled(t *testing.T) {
+	s, _ := setupServer(t)
+	s.settings.ExecutionDisabled = true
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /presentation", s.HandlerIndex)
+	mux.HandleFunc("GET /slides/{id}", s.HandlerSlideByIndex)
+	mux.HandleFunc("POST /commands/{id}/start", s.HandlerCommandStart)
+	mux.HandleFunc("POST /commands/{id}/stop", s.HandlerCommandStop)
+	mux.HandleFunc("GET /ws", s.HandlerWebSocket)
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/presentation", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `data-read-only="true"`)
+
+	// the mock command manager fails the test if the slide change tries to stop or clear anything
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/slides/1", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "view only")
+
+	for _, path := range []string{"/commands/1/start", "/commands/1/stop"} {
+		rr = httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("POST", path, nil))
+		assert.Equal(t, http.StatusForbidden, rr.Code, path)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/ws", nil))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+}
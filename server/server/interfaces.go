@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"io"
 	"net/http"
 
 	"github.com/gorilla/websocket"
@@ -10,11 +11,16 @@ import (
 )
 
 type IPresentation interface {
+	LoadSettings(commandsFile string) (types.Settings, error)
+	GetSettings() types.Settings
+	GetSources() [][]byte
 	SplitContent(commandsFile string) (slideContent []string, err error)
 	ParseSlides(contents []string)
 	ParseSlide(content string)
 	GetSlide(idx int) (types.Slide, error)
 	GetSlideCount() int
+	GetSlideTitles() []string
+	SearchSlides(query string, commands bool) []types.Slide
 }
 
 type IPresentationServer interface {
@@ -27,6 +33,13 @@ type IPresentationServer interface {
 	HandlerCommandStart(w http.ResponseWriter, r *http.Request)
 	HandlerCommandStatus(w http.ResponseWriter, r *http.Request)
 	HandlerCommandStop(w http.ResponseWriter, r *http.Request)
+	HandlerCommandReplay(w http.ResponseWriter, r *http.Request)
+	HandlerPlayback(w http.ResponseWriter, r *http.Request)
+	HandlerContents(w http.ResponseWriter, r *http.Request)
+	HandlerSearch(w http.ResponseWriter, r *http.Request)
+	HandlerOverview(w http.ResponseWriter, r *http.Request)
+	HandlerSession(w http.ResponseWriter, r *http.Request)
+	HandlerAsset(w http.ResponseWriter, r *http.Request)
 }
 
 //go:generate go tool mockgen -destination=../mocks/mock_$GOPACKAGE.go -package=mocks github.com/joshjennings98/backend-demo/server/v2/$GOPACKAGE ICommandManager
@@ -34,9 +47,18 @@ type IPresentationServer interface {
 type ICommandManager interface {
 	IsWebsocketConnected() bool
 	SetWebsocketConnection(ws *websocket.Conn)
+	SetTerminalRecorder(recorder io.Writer)
 	CloseWebsocketConnection() error
-	Run(commands []string) error
+	Run(slide types.Slide, client string) error
+	Exec(ctx context.Context, commands []string, output io.Writer) error
 	Stop() error
+	Shutdown(ctx context.Context) error
 	Clear() error
 	IsRunning() bool
+	LastOutput(id int) string
+	Result(id int) types.CommandResult
+	NextStep(id int) int
+	ControlPlayback(action string) (types.PlaybackState, error)
+	Replay(slide types.Slide) error
+	CheckInterpreters(slides []types.Slide) error
 }
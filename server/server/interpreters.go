@@ -0,0 +1,108 @@
+package server
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/joshjennings98/backend-demo/server/v2/types"
+)
+
+// scriptFlags are the flags that interpreters, other than shells which all take -c, use to run a script from an argument
+var scriptFlags = map[string]string{
+	"node":   "-e",
+	"perl":   "-e",
+	"ruby":   "-e",
+	"pwsh":   "-Command",
+	"deno":   "eval",
+	"python": "-c",
+}
+
+// posixShells are the shells that step-through slides can use, since each line is run with POSIX shell syntax
+var posixShells = []string{"sh", "bash", "dash", "zsh", "ksh", "ash"}
+
+// interpreterArgs returns the arguments to run a script with a shell or interpreter, which can have its own arguments
+// such as "bash -eu" or "python3 -u"
+func interpreterArgs(shell, script string) []string {
+	args := strings.Fields(shell)
+	flag, _ := scriptFlag(args[0])
+	return append(args, flag, script)
+}
+
+// scriptFlag returns the flag a shell or interpreter runs a script from an argument with and whether it is a shell
+func scriptFlag(program string) (flag string, shell bool) {
+	name := strings.TrimSuffix(filepath.Base(program), ".exe")
+	// versioned interpreters such as python3 or python3.12 take the same flag
+	flag = cmp.Or(scriptFlags[name], scriptFlags[strings.TrimRight(name, "0123456789.")])
+	if flag == "" {
+		return "-c", true
+	}
+
+	return
+}
+
+// isPOSIXShell checks whether a slide's commands run in a POSIX shell, rather than another shell such as fish or an
+// interpreter such as python3
+func (c *commandManager) isPOSIXShell(slide types.Slide) bool {
+	name := strings.TrimSuffix(filepath.Base(strings.Fields(c.interpreter(slide))[0]), ".exe")
+	return slices.Contains(posixShells, name)
+}
+
+// interpreter is the shell or interpreter a slide's commands run with
+func (c *commandManager) interpreter(slide types.Slide) string {
+	return Interpreter(c.settings, slide)
+}
+
+// Interpreter is the shell or interpreter that runs a slide's commands, with any arguments, from the slide or the
+// presentation. The setup and teardown commands run with the interpreter of a slide without one of its own.
+func Interpreter(settings types.Settings, slide types.Slide) string {
+	return cmp.Or(strings.TrimSpace(slide.Shell), strings.TrimSpace(settings.Shell), defaultShell)
+}
+
+// CheckInterpreters looks for the shells and interpreters the slides, and the setup and teardown commands, use, and
+// checks that step-through slides use a shell, so a problem is found before presenting
+func (c *commandManager) CheckInterpreters(slides []types.Slide) (err error) {
+	var missing, notShells []string
+	checked := map[string]bool{}
+	find := func(program, use string) {
+		if _, ok := checked[program]; !ok {
+			_, lookErr := exec.LookPath(program)
+			checked[program] = lookErr == nil
+		}
+
+		if !checked[program] {
+			missing = append(missing, fmt.Sprintf("%v (%v)", program, use))
+		}
+	}
+
+	if len(c.settings.Setup) > 0 || len(c.settings.Teardown) > 0 {
+		find(strings.Fields(c.interpreter(types.Slide{}))[0], "setup and teardown")
+	}
+
+	for _, slide := range slides {
+		if slide.SlideType != types.SlideTypeCommand {
+			continue
+		}
+
+		program := strings.Fields(c.interpreter(slide))[0]
+		find(program, fmt.Sprintf("slide %v", slide.ID+1))
+
+		// the lines are sent to the shell one at a time with POSIX shell syntax
+		if slide.Steps && !c.isPOSIXShell(slide) {
+			notShells = append(notShells, fmt.Sprintf("%v (slide %v)", program, slide.ID+1))
+		}
+	}
+
+	if len(missing) > 0 {
+		err = fmt.Errorf("could not find shell or interpreter: %v", strings.Join(missing, ", "))
+	}
+	if len(notShells) > 0 {
+		err = errors.Join(err, fmt.Errorf("step-through slides need a POSIX shell such as sh or bash: %v", strings.Join(notShells, ", ")))
+	}
+
+	return
+}
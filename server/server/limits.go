@@ -0,0 +1,78 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+
+	"github.com/joshjennings98/backend-demo/server/v2/types"
+)
+
+var (
+	memoryErrorRegex    = regexp.MustCompile(`(?i)cannot allocate memory|out of memory|memoryerror|bad_alloc`)
+	openFilesErrorRegex = regexp.MustCompile(`(?i)too many open files`)
+)
+
+// limitError is the cause of a command being stopped, or failing, because it hit one of its limits
+type limitError struct {
+	limit string
+	value string
+}
+
+func (e *limitError) Error() string {
+	return fmt.Sprintf("%v limit of %v exceeded", e.limit, e.value)
+}
+
+// limitWriter stops the command once it has written more than its maximum output
+type limitWriter struct {
+	w         io.Writer
+	remaining int64
+	stopped   bool
+	exceeded  func()
+}
+
+func newLimitWriter(w io.Writer, limit types.ByteSize, exceeded func()) io.Writer {
+	if limit <= 0 {
+		return w
+	}
+
+	return &limitWriter{w: w, remaining: int64(limit), exceeded: exceeded}
+}
+
+// Write drops anything past the limit instead of returning an error so the command is stopped rather than failing on a broken pipe
+func (l *limitWriter) Write(p []byte) (n int, err error) {
+	if l.stopped {
+		return len(p), nil
+	}
+
+	if int64(len(p)) <= l.remaining {
+		l.remaining -= int64(len(p))
+		return l.w.Write(p)
+	}
+
+	if l.remaining > 0 {
+		_, err = l.w.Write(p[:l.remaining])
+		l.remaining = 0
+	}
+
+	l.stopped = true
+	l.exceeded()
+	return len(p), err
+}
+
+// failedLimit works out which limit, if any, made a command fail. Running out of memory or files can only be
+// recognised from the error messages at the end of the output.
+func failedLimit(err error, limits types.Limits, output string) *limitError {
+	switch {
+	case err == nil:
+		return nil
+	case limits.CPU > 0 && cpuLimitExceeded(err):
+		return &limitError{limit: "cpu", value: limits.CPU.String()}
+	case limits.Memory > 0 && memoryErrorRegex.MatchString(output):
+		return &limitError{limit: "memory", value: limits.Memory.String()}
+	case limits.OpenFiles > 0 && openFilesErrorRegex.MatchString(output):
+		return &limitError{limit: "open files", value: fmt.Sprint(limits.OpenFiles)}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/joshjennings98/backend-demo/server/v2/types"
+)
+
+func TestLimitWriter(t *testing.T) {
+	var output bytes.Buffer
+	exceeded := 0
+	writer := newLimitWriter(&output, 8, func() { exceeded++ })
+
+	// filling the limit exactly isn't exceeding it
+	n, err := writer.Write([]byte("12345678"))
+	require.NoError(t, err)
+	assert.Equal(t, 8, n)
+	assert.Zero(t, exceeded)
+
+	n, err = writer.Write([]byte("9"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, 1, exceeded)
+
+	_, err = writer.Write([]byte("more"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, exceeded, "should only stop the command once")
+	assert.Equal(t, "12345678", output.String())
+
+	t.Run("Split write", func(t *testing.T) {
+		output.Reset()
+		writer := newLimitWriter(&output, 4, func() { exceeded++ })
+		_, err := writer.Write([]byte("abcdef"))
+		require.NoError(t, err)
+		assert.Equal(t, "abcd", output.String())
+	})
+
+	t.Run("Unlimited", func(t *testing.T) {
+		assert.Same(t, &output, newLimitWriter(&output, 0, nil))
+	})
+}
+
+func TestParseLimits(t *testing.T) {
+	limits, err := parseLimits(map[string]string{
+		"timeout":    "1m30s",
+		"cpu":        "10s",
+		"memory":     "256MB",
+		"open-files": "128",
+		"max-output": "1 GiB",
+		"title":      "ignored",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, types.Limits{
+		Timeout:   90 * time.Second,
+		CPU:       10 * time.Second,
+		Memory:    256 << 20,
+		OpenFiles: 128,
+		MaxOutput: 1 << 30,
+	}, limits)
+	assert.Equal(t, "256M", limits.Memory.String())
+
+	_, err = parseLimits(map[string]string{"memory": "lots", "timeout": "soon"})
+	assert.ErrorContains(t, err, "invalid size 'lots'")
+	assert.ErrorContains(t, err, `invalid duration "soon"`)
+}
+
+func TestFailedLimit(t *testing.T) {
+	limits := types.Limits{Memory: 64 << 20, OpenFiles: 16}
+	failed := errors.New("exit status 1")
+
+	assert.Nil(t, failedLimit(nil, limits, "MemoryError"))
+	assert.Nil(t, failedLimit(failed, types.Limits{}, "MemoryError"))
+	assert.Equal(t, "memory limit of 64M exceeded", failedLimit(failed, limits, "MemoryError").Error())
+	assert.Equal(t, "open files limit of 16 exceeded", failedLimit(failed, limits, "open: too many open files").Error())
+	assert.Nil(t, failedLimit(failed, limits, "command not found"))
+}
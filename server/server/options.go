@@ -0,0 +1,89 @@
+package server
+
+import (
+	"github.com/joshjennings98/backend-demo/server/v2/types"
+)
+
+// Option overrides a setting from the front matter of the commands file.
+type Option func(settings *types.Settings)
+
+// WithListenAddress sets the host or IP address the server binds to.
+func WithListenAddress(address string) Option {
+	return func(settings *types.Settings) {
+		settings.Listen.Address = address
+	}
+}
+
+// WithSocket makes the server listen on a unix domain socket instead of a TCP port.
+func WithSocket(path string) Option {
+	return func(settings *types.Settings) {
+		settings.Listen.Socket = path
+	}
+}
+
+// WithAllowedOrigins sets the origins, other than localhost, that can open a websocket connection.
+func WithAllowedOrigins(origins []string) Option {
+	return func(settings *types.Settings) {
+		settings.Listen.AllowedOrigins = origins
+	}
+}
+
+// WithAuth requires the presenter to log in with the token printed at startup before they can run commands.
+func WithAuth(enabled bool) Option {
+	return func(settings *types.Settings) {
+		settings.Auth = enabled
+	}
+}
+
+// WithTLSCertificate serves the presentation over HTTPS with the certificate and key files.
+func WithTLSCertificate(cert, key string) Option {
+	return func(settings *types.Settings) {
+		settings.TLS.Cert = cert
+		settings.TLS.Key = key
+	}
+}
+
+// WithAutoTLS serves the presentation over HTTPS with a cached self-signed certificate for the hosts.
+func WithAutoTLS(hosts []string) Option {
+	return func(settings *types.Settings) {
+		settings.TLS.Auto = true
+		settings.TLS.Hosts = hosts
+	}
+}
+
+// WithRecordings records what every command sends to the terminal as an asciicast file in dir.
+func WithRecordings(dir string) Option {
+	return func(settings *types.Settings) {
+		settings.Record.Dir = dir
+	}
+}
+
+// WithSessionRecording records the whole presentation to a file that can be replayed in the browser.
+func WithSessionRecording(path string) Option {
+	return func(settings *types.Settings) {
+		settings.Record.Session = path
+	}
+}
+
+// WithTranscript appends every command that is run from the browser, and who ran it, to a transcript file.
+func WithTranscript(path string) Option {
+	return func(settings *types.Settings) {
+		settings.Transcript.File = path
+	}
+}
+
+// WithExecutionDisabled presents the slides without letting anyone run their commands, or the setup and teardown commands.
+func WithExecutionDisabled() Option {
+	return func(settings *types.Settings) {
+		settings.ExecutionDisabled = true
+	}
+}
+
+// WithSafeMode sanitizes the HTML of slides, only allowing iframes from the hosts, and disables execution.
+func WithSafeMode(iframeHosts []string) Option {
+	return func(settings *types.Settings) {
+		settings.Safe.Enabled = true
+		settings.Safe.IframeHosts = iframeHosts
+		settings.ExecutionDisabled = true
+	}
+}
@@ -0,0 +1,205 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/joshjennings98/backend-demo/server/v2/types"
+)
+
+// processSetupScript runs the lines that processes share in a POSIX shell, then saves the variables it exported and the
+// directory it ended up in so the processes can carry on from them
+const processSetupScript = "{\n%v\n}\nstatus=$?\nexport -p > %v\npwd > %v\nexit $status\n"
+
+// processColors are the colors of the name prefixes of processes that run together, in the same order as docker compose
+var processColors = []int{36, 33, 32, 35, 34, 31}
+
+// parseProcesses reads the commands of a slide that run together, e.g. $[producer] ./produce.sh, and the slide's other
+// lines which run once before they start
+func parseProcesses(content string) (setup []string, processes []types.Process) {
+	for line := range strings.SplitSeq(content, "\n") {
+		if match := processRegex.FindStringSubmatch(line); match != nil {
+			processes = append(processes, types.Process{Name: match[1], Command: match[2]})
+		} else if strings.HasPrefix(line, "$ ") || strings.HasPrefix(line, "$! ") {
+			setup = append(setup, strings.TrimPrefix(strings.TrimPrefix(line, "$! "), "$ "))
+		}
+	}
+
+	if len(processes) == 0 {
+		setup = nil
+	}
+
+	return
+}
+
+// prefixWriter writes each complete line of a process's output with its name in front, like docker compose
+type prefixWriter struct {
+	w       io.Writer
+	mu      *sync.Mutex
+	prefix  []byte
+	pending []byte
+}
+
+func newPrefixWriter(w io.Writer, mu *sync.Mutex, name string, width int, color int) *prefixWriter {
+	return &prefixWriter{
+		w:      w,
+		mu:     mu,
+		prefix: fmt.Appendf(nil, "\033[1;%vm%-*v |\033[0m ", color, width, name),
+	}
+}
+
+func (p *prefixWriter) Write(b []byte) (n int, err error) {
+	p.pending = append(p.pending, b...)
+
+	// only whole lines are written so the output of the processes doesn't get mixed up within a line
+	end := bytes.LastIndexByte(p.pending, '\n') + 1
+	if end > 0 {
+		err = p.writeLines(p.pending[:end])
+		p.pending = slices.Delete(p.pending, 0, end)
+	}
+
+	n = len(b)
+	return
+}
+
+// Close writes anything after the last line break
+func (p *prefixWriter) Close() (err error) {
+	if len(p.pending) > 0 {
+		err = p.writeLines(append(p.pending, '\n'))
+		p.pending = nil
+	}
+
+	return
+}
+
+func (p *prefixWriter) writeLines(lines []byte) (err error) {
+	var buf bytes.Buffer
+	for line := range bytes.Lines(lines) {
+		buf.Write(p.prefix)
+		buf.Write(line)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err = p.w.Write(buf.Bytes())
+	return
+}
+
+// runProcesses runs the commands of a slide together, stopping them all if the slide is stopped. The slide's other lines
+// run once before them, and the processes aren't started if they fail. The timeout and output limits apply to the
+// processes as a whole and the others apply to each of them.
+func (c *commandManager) runProcesses(ctx context.Context, slide types.Slide, limits types.Limits, output io.Writer) (err error) {
+	var state string
+	if len(slide.ProcessSetup) > 0 {
+		if state, err = os.MkdirTemp("", "backend-demo-setup-"); err != nil {
+			err = fmt.Errorf("could not create directory for the setup of the processes: %w", err)
+			return
+		}
+		defer func() { _ = os.RemoveAll(state) }()
+
+		if err = c.runProcessSetup(ctx, slide, limits, output, state); err != nil {
+			return
+		}
+	}
+
+	width := 0
+	for _, process := range slide.Processes {
+		width = max(width, len(process.Name))
+	}
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs = make([]error, len(slide.Processes))
+	)
+
+	for i, process := range slide.Processes {
+		processSlide := slide
+		processSlide.ExecuteContent = slices.Concat(processSetupState(state), []string{process.Command})
+
+		cmd := c.command(ctx, processSlide, limits)
+		color := processColors[i%len(processColors)]
+		prefixed := newPrefixWriter(output, &mu, process.Name, width, color)
+		cmd.Stdout = prefixed
+		cmd.Stderr = prefixed
+
+		wg.Go(func() {
+			errs[i] = cmd.Run()
+			_ = prefixed.Close()
+			if ctx.Err() != nil {
+				// clean up anything the process left running in the background
+				killProcessGroup(cmd)
+			}
+
+			c.logger.Info("process exited", "process", process.Name, "error", errs[i])
+
+			status := "stopped"
+			if ctx.Err() == nil {
+				code := 0
+				var exitErr *exec.ExitError
+				if errors.As(errs[i], &exitErr) {
+					code = exitErr.ExitCode()
+				}
+				status = fmt.Sprintf("exited with code %v", code)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			_, _ = fmt.Fprintf(output, "\033[1;%vm%v %v\033[0m\n", color, process.Name, status)
+		})
+	}
+
+	wg.Wait()
+	err = errors.Join(errs...)
+	return
+}
+
+// runProcessSetup runs the lines that the processes of a slide share, a shell saves its state to dir for the processes
+func (c *commandManager) runProcessSetup(ctx context.Context, slide types.Slide, limits types.Limits, output io.Writer, dir string) (err error) {
+	setup := slide
+	setup.ExecuteContent = slide.ProcessSetup
+	if c.isPOSIXShell(slide) {
+		script := fmt.Sprintf(processSetupScript, strings.Join(slide.ProcessSetup, "\n"), shellQuote(filepath.Join(dir, "env")), shellQuote(filepath.Join(dir, "dir")))
+		setup.ExecuteContent = []string{script}
+	}
+
+	cmd := c.command(ctx, setup, limits)
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	err = cmd.Run()
+	if ctx.Err() != nil {
+		// clean up anything the setup left running in the background
+		killProcessGroup(cmd)
+	} else if err != nil {
+		_, _ = fmt.Fprintf(output, "\033[1msetup exited with code %v, the processes weren't started\033[0m\n", exitCode(err))
+	}
+
+	c.logger.Info("process setup exited", "error", err)
+	return
+}
+
+// processSetupState are the lines that load what the setup of a slide's processes left behind in dir, the variables it
+// exported and the directory it ended up in, which only a shell saves
+func processSetupState(dir string) []string {
+	if dir == "" {
+		return nil
+	}
+
+	// a setup that exits by itself doesn't leave anything behind
+	env := filepath.Join(dir, "env")
+	if _, err := os.Stat(env); err != nil {
+		return nil
+	}
+
+	return []string{fmt.Sprintf(`cd "$(cat %v)" && . %v || exit`, shellQuote(filepath.Join(dir, "dir")), shellQuote(env))}
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/joshjennings98/backend-demo/server/v2/types"
+)
+
+func TestParseProcesses(t *testing.T) {
+	setup, processes := parseProcesses("$ echo hello")
+	assert.Empty(t, setup)
+	assert.Empty(t, processes)
+
+	setup, processes = parseProcesses("$ export PORT=8080\n$[server] ./serve --port $PORT\n$! echo ready\n$[client-1] curl localhost:$PORT")
+	assert.Equal(t, []string{"export PORT=8080", "echo ready"}, setup)
+	assert.Equal(t, []types.Process{
+		{Name: "server", Command: "./serve --port $PORT"},
+		{Name: "client-1", Command: "curl localhost:$PORT"},
+	}, processes)
+}
+
+func TestPrefixWriter(t *testing.T) {
+	var buf bytes.Buffer
+	var mu sync.Mutex
+	w := newPrefixWriter(&buf, &mu, "api", 6, 36)
+
+	_, err := w.Write([]byte("first line\nsecond "))
+	require.NoError(t, err)
+	assert.Equal(t, "\033[1;36mapi    |\033[0m first line\n", buf.String())
+
+	_, err = w.Write([]byte("line\nno newline"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	assert.Equal(t, "\033[1;36mapi    |\033[0m first line\n\033[1;36mapi    |\033[0m second line\n\033[1;36mapi    |\033[0m no newline\n", buf.String())
+}
+
+func TestCommandManager_Processes(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	cm := newCommandManager(logger, types.Settings{}, nil)
+
+	ws, cleanup := setupWebSocket(t, cm)
+	defer cleanup()
+
+	dir := t.TempDir()
+	log := filepath.Join(dir, "setup.log")
+	slide := types.Slide{ID: 1, ProcessSetup: []string{"echo setup >> " + log, "export ITEM=apple", "cd " + dir}, Processes: []types.Process{
+		{Name: "producer", Command: "echo made $ITEM in $PWD"},
+		{Name: "consumer", Command: "sleep 0.1; echo ate $ITEM; exit 2"},
+	}}
+	require.NoError(t, cm.Run(slide, ""))
+
+	// the shared lines run once, and the processes carry on from the variables and directory they left behind
+	terminal := readTerminal(t, ws, "consumer exited with code 2")
+	assert.Contains(t, terminal, "\033[1;36mproducer |\033[0m made apple in "+dir+"\n\r")
+	assert.Contains(t, terminal, "\033[1;33mconsumer |\033[0m ate apple\n\r")
+	assert.Contains(t, terminal, "producer exited with code 0")
+
+	data, err := os.ReadFile(log)
+	require.NoError(t, err)
+	assert.Equal(t, "setup\n", string(data))
+
+	t.Run("Setup fails", func(t *testing.T) {
+		slide := types.Slide{ID: 3, ProcessSetup: []string{"echo preparing", "false"}, Processes: []types.Process{
+			{Name: "a", Command: "echo started a"},
+			{Name: "b", Command: "echo started b"},
+		}}
+		require.NoError(t, cm.Run(slide, ""))
+		assert.Eventually(t, func() bool { return !cm.IsRunning() }, 5*time.Second, 10*time.Millisecond)
+
+		output := cm.LastOutput(slide.ID)
+		assert.Contains(t, output, "setup exited with code 1, the processes weren't started")
+		assert.NotContains(t, output, "started a")
+		assert.NotContains(t, output, "started b")
+	})
+
+	t.Run("Stop", func(t *testing.T) {
+		slide := types.Slide{ID: 2, Processes: []types.Process{
+			{Name: "a", Command: "sleep 10"},
+			{Name: "b", Command: "sleep 10"},
+		}}
+
+		start := time.Now()
+		require.NoError(t, cm.Run(slide, ""))
+		assert.Eventually(t, cm.IsRunning, time.Second, 10*time.Millisecond)
+		time.Sleep(100 * time.Millisecond)
+
+		require.NoError(t, cm.Stop())
+		assert.Eventually(t, func() bool {
+			output := cm.LastOutput(slide.ID)
+			return strings.Contains(output, "a stopped") && strings.Contains(output, "b stopped")
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.Less(t, time.Since(start), 5*time.Second)
+	})
+}
@@ -0,0 +1,282 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/joshjennings98/backend-demo/server/v2/types"
+)
+
+const (
+	PlaybackPause  = "pause"
+	PlaybackFaster = "faster"
+	PlaybackSlower = "slower"
+	PlaybackSkip   = "skip"
+
+	minPlaybackSpeed = 0.25
+	maxPlaybackSpeed = 16
+)
+
+var (
+	ErrNotPlaying            = errors.New("no recording is being played back")
+	ErrUnknownPlaybackAction = errors.New("unknown playback action")
+)
+
+// castEvent is output from a recording and when it was written
+type castEvent struct {
+	time time.Duration
+	data string
+}
+
+// loadCast reads the header and output of an asciicast v2 file
+func loadCast(path string) (header castHeader, events []castEvent, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		err = fmt.Errorf("could not open recording: %w", err)
+		return
+	}
+	defer func() { _ = file.Close() }()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1024*1024)
+	if !scanner.Scan() {
+		err = fmt.Errorf("recording %v is empty", path)
+		if scanErr := scanner.Err(); scanErr != nil {
+			err = fmt.Errorf("could not read recording %v: %w", path, scanErr)
+		}
+		return
+	}
+
+	if err = json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		err = fmt.Errorf("could not read header of recording %v: %w", path, err)
+		return
+	}
+	if header.Version != 2 {
+		err = fmt.Errorf("recording %v is asciicast version %v, only version 2 is supported", path, header.Version)
+		return
+	}
+
+	for line := 2; scanner.Scan(); line++ {
+		var event []any
+		if err = json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			err = fmt.Errorf("could not read line %v of recording %v: %w", line, path, err)
+			return
+		}
+
+		if len(event) != 3 {
+			err = fmt.Errorf("line %v of recording %v isn't an event", line, path)
+			return
+		}
+
+		seconds, isTime := event[0].(float64)
+		kind, _ := event[1].(string)
+		data, isData := event[2].(string)
+		if !isTime || !isData {
+			err = fmt.Errorf("line %v of recording %v isn't an event", line, path)
+			return
+		}
+
+		// input, markers and resizes aren't shown in the terminal
+		if kind == "o" {
+			events = append(events, castEvent{time: time.Duration(seconds * float64(time.Second)), data: data})
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		err = fmt.Errorf("could not read recording %v: %w", path, err)
+	}
+
+	return
+}
+
+// parsePlayback reads how a recording is played back from a slide's directives, e.g. <!-- speed: 2 --> or
+// <!-- idle-limit: 1s -->
+func parsePlayback(directives map[string]string) (playback types.Playback, err error) {
+	var errs []error
+	if value, ok := directives["speed"]; ok {
+		playback.Speed, err = strconv.ParseFloat(value, 64)
+		if err == nil && (playback.Speed < minPlaybackSpeed || playback.Speed > maxPlaybackSpeed) {
+			err = fmt.Errorf("speed %v should be between %v and %v", value, minPlaybackSpeed, maxPlaybackSpeed)
+		}
+		errs = append(errs, err)
+	}
+	if value, ok := directives["idle-limit"]; ok {
+		playback.IdleLimit, err = time.ParseDuration(value)
+		errs = append(errs, err)
+	}
+
+	err = errors.Join(errs...)
+	return
+}
+
+// player is the state of the recording being played back, which the presenter can change while it plays
+type player struct {
+	mu    sync.Mutex
+	state types.PlaybackState
+	skip  bool
+	// changed is signalled when the presenter changes the state so the current wait can be recalculated
+	changed chan struct{}
+}
+
+func newPlayer(speed float64) *player {
+	if speed <= 0 {
+		speed = 1
+	}
+
+	return &player{state: types.PlaybackState{Speed: speed}, changed: make(chan struct{}, 1)}
+}
+
+func (p *player) control(action string) (state types.PlaybackState, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch action {
+	case PlaybackPause:
+		p.state.Paused = !p.state.Paused
+	case PlaybackFaster:
+		p.state.Speed = min(p.state.Speed*2, maxPlaybackSpeed)
+	case PlaybackSlower:
+		p.state.Speed = max(p.state.Speed/2, minPlaybackSpeed)
+	case PlaybackSkip:
+		p.skip = true
+	default:
+		err = fmt.Errorf("%w: %v", ErrUnknownPlaybackAction, action)
+		return
+	}
+
+	select {
+	case p.changed <- struct{}{}:
+	default:
+	}
+
+	state = p.state
+	return
+}
+
+// wait waits for the gap before the next output, taking into account the presenter changing the speed, pausing or
+// skipping while it waits
+func (p *player) wait(ctx context.Context, gap time.Duration) (err error) {
+	for gap > 0 {
+		p.mu.Lock()
+		state, skip := p.state, p.skip
+		p.skip = false
+		p.mu.Unlock()
+
+		if skip {
+			return
+		}
+
+		if state.Paused {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-p.changed:
+				continue
+			}
+		}
+
+		start := time.Now()
+		timer := time.NewTimer(time.Duration(float64(gap) / state.Speed))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+			return
+		case <-p.changed:
+			timer.Stop()
+			gap -= time.Duration(float64(time.Since(start)) * state.Speed)
+		}
+	}
+
+	return
+}
+
+func (c *commandManager) ControlPlayback(action string) (state types.PlaybackState, err error) {
+	c.playerMu.Lock()
+	player := c.player
+	c.playerMu.Unlock()
+
+	if player == nil {
+		err = ErrNotPlaying
+		return
+	}
+
+	return player.control(action)
+}
+
+// play replays the recording of a playback slide into the terminal with its original timing
+func (c *commandManager) play(ctx context.Context, slide types.Slide, output *outputTail) {
+	c.running.Store(true)
+	defer func() {
+		c.running.Store(false)
+		c.wg.Done()
+	}()
+
+	c.replay(ctx, slide.Recording, slide.Playback, output)
+}
+
+// replay plays a recording into the terminal, which the presenter can control while it plays, and reports whether it
+// played to the end
+func (c *commandManager) replay(ctx context.Context, recording string, playback types.Playback, output *outputTail) (completed bool) {
+	c.logger.Info("playing recording", "file", recording)
+
+	_, events, err := loadCast(recording)
+	if err != nil {
+		c.logger.Error("could not play recording", "error", err)
+		return
+	}
+
+	player := newPlayer(playback.Speed)
+	c.playerMu.Lock()
+	c.player = player
+	c.playerMu.Unlock()
+
+	defer func() {
+		c.playerMu.Lock()
+		if c.player == player {
+			c.player = nil
+		}
+		c.playerMu.Unlock()
+	}()
+
+	// recordings already have the line endings the terminal needs
+	terminal := c.terminal(ctx)
+	terminal.raw = true
+	redacted := c.redactor.Writer(io.MultiWriter(output, terminal))
+
+	completed = true
+	var previous time.Duration
+	for _, event := range events {
+		gap := event.time - previous
+		previous = event.time
+		if playback.IdleLimit > 0 {
+			gap = min(gap, playback.IdleLimit)
+		}
+
+		if err = player.wait(ctx, gap); err == nil {
+			_, err = io.WriteString(redacted, event.data)
+		}
+		if err != nil {
+			c.logger.Info("playback stopped", "reason", err)
+			completed = false
+			break
+		}
+	}
+
+	// anything held back by the redactor is written before the caller writes anything after the recording
+	_ = redacted.Close()
+	if completed {
+		c.logger.Info("playback completed")
+	}
+
+	return
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/joshjennings98/backend-demo/server/v2/types"
+)
+
+// writeCast writes an asciicast file for a test
+func writeCast(t *testing.T, lines ...string) (path string) {
+	t.Helper()
+
+	path = filepath.Join(t.TempDir(), "demo.cast")
+	var content string
+	for _, line := range lines {
+		content += line + "\n"
+	}
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return
+}
+
+func TestLoadCast(t *testing.T) {
+	path := writeCast(t,
+		`{"version": 2, "width": 80, "height": 24, "command": "curl example.com"}`,
+		`[0.5, "o", "hello\r\n"]`,
+		`[0.75, "i", "q"]`,
+		`[1.25, "o", "world\r\n"]`,
+	)
+
+	header, events, err := loadCast(path)
+	require.NoError(t, err)
+	assert.Equal(t, "curl example.com", header.Command)
+	assert.Equal(t, []castEvent{
+		{time: 500 * time.Millisecond, data: "hello\r\n"},
+		{time: 1250 * time.Millisecond, data: "world\r\n"},
+	}, events)
+
+	testCases := []struct {
+		name  string
+		lines []string
+		err   string
+	}{
+		{name: "Empty", err: "is empty"},
+		{name: "Version 1", lines: []string{`{"version": 1, "stdout": []}`}, err: "only version 2 is supported"},
+		{name: "Invalid event", lines: []string{`{"version": 2}`, `[0.5, "o"]`}, err: "line 2 of recording"},
+		{name: "Invalid JSON", lines: []string{`{"version": 2}`, `not json`}, err: "could not read line 2"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := loadCast(writeCast(t, tc.lines...))
+			assert.ErrorContains(t, err, tc.err)
+		})
+	}
+
+	_, _, err = loadCast(filepath.Join(t.TempDir(), "missing.cast"))
+	assert.ErrorContains(t, err, "could not open recording")
+}
+
+func TestParsePlayback(t *testing.T) {
+	playback, err := parsePlayback(map[string]string{"speed": "2", "idle-limit": "1s"})
+	require.NoError(t, err)
+	assert.Equal(t, types.Playback{Speed: 2, IdleLimit: time.Second}, playback)
+
+	_, err = parsePlayback(map[string]string{"speed": "100"})
+	assert.ErrorContains(t, err, "should be between")
+
+	_, err = parsePlayback(map[string]string{"idle-limit": "soon"})
+	assert.Error(t, err)
+}
+
+func TestPlayer(t *testing.T) {
+	p := newPlayer(0)
+
+	state, err := p.control(PlaybackFaster)
+	require.NoError(t, err)
+	assert.Equal(t, types.PlaybackState{Speed: 2}, state)
+
+	for range 10 {
+		state, _ = p.control(PlaybackSlower)
+	}
+	assert.Equal(t, types.PlaybackState{Speed: minPlaybackSpeed}, state)
+
+	_, err = p.control("rewind")
+	assert.ErrorIs(t, err, ErrUnknownPlaybackAction)
+
+	t.Run("Skip", func(t *testing.T) {
+		done := make(chan error)
+		go func() { done <- p.wait(context.Background(), time.Hour) }()
+
+		time.Sleep(10 * time.Millisecond)
+		_, err := p.control(PlaybackSkip)
+		require.NoError(t, err)
+
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("skipping didn't end the wait")
+		}
+	})
+
+	t.Run("Pause", func(t *testing.T) {
+		p := newPlayer(1)
+		_, err := p.control(PlaybackPause)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, p.wait(ctx, time.Millisecond), context.DeadlineExceeded)
+
+		done := make(chan error)
+		go func() { done <- p.wait(context.Background(), time.Millisecond) }()
+
+		state, err := p.control(PlaybackPause)
+		require.NoError(t, err)
+		assert.False(t, state.Paused)
+		assert.NoError(t, <-done)
+	})
+}
+
+func TestCommandManager_Playback(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	cm := newCommandManager(logger, types.Settings{}, nil)
+
+	ws, cleanup := setupWebSocket(t, cm)
+	defer cleanup()
+
+	_, err := cm.ControlPlayback(PlaybackPause)
+	assert.ErrorIs(t, err, ErrNotPlaying)
+
+	slide := types.Slide{
+		ID:        1,
+		SlideType: types.SlideTypePlayback,
+		Recording: writeCast(t, `{"version": 2, "width": 80, "height": 24}`, `[0.1, "o", "one\r\n"]`, `[60, "o", "two\r\n"]`),
+		Playback:  types.Playback{IdleLimit: 100 * time.Millisecond},
+	}
+
+	start := time.Now()
+	require.NoError(t, cm.Run(slide, ""))
+
+	// the recording is written as it is, without extra carriage returns, and the long pause is cut short
+	assert.Equal(t, "one\r\ntwo\r\n", readTerminal(t, ws, "two")[len("\033[2J\033[H"):])
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.Eventually(t, func() bool { return !cm.IsRunning() }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "one\ntwo", cm.LastOutput(slide.ID))
+}
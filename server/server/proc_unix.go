@@ -0,0 +1,69 @@
+//go:build !windows
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	"github.com/joshjennings98/backend-demo/server/v2/types"
+)
+
+// setProcessGroup runs the command in its own process group so that stopping it also stops anything it started in the background
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+	}
+	cmd.WaitDelay = stopTimeout
+}
+
+// killProcessGroup kills any processes left in the command's process group
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// withLimits wraps the command in a shell that sets the resource limits with ulimit and then replaces itself with the command
+func withLimits(args []string, limits types.Limits) []string {
+	var ulimits []string
+	if limits.CPU > 0 {
+		// the hard limit is a second later so the command is sent SIGXCPU, rather than killed, and it can be reported
+		seconds := int(math.Ceil(limits.CPU.Seconds()))
+		ulimits = append(ulimits, fmt.Sprintf("ulimit -t %v && ulimit -S -t %v", seconds+1, seconds))
+	}
+	if limits.Memory > 0 {
+		ulimits = append(ulimits, fmt.Sprintf("ulimit -v %v", (int64(limits.Memory)+1023)/1024))
+	}
+	if limits.OpenFiles > 0 {
+		ulimits = append(ulimits, fmt.Sprintf("ulimit -n %v", limits.OpenFiles))
+	}
+
+	if len(ulimits) == 0 {
+		return args
+	}
+
+	script := strings.Join(ulimits, " && ") + ` && exec "$@"`
+	return append([]string{defaultShell, "-c", script, defaultShell}, args...)
+}
+
+// cpuLimitExceeded checks whether the command, or the last process in its script, was killed for using too much CPU time
+func cpuLimitExceeded(err error) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return false
+	}
+
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok {
+		return false
+	}
+
+	// shells report a child killed by a signal with an exit code of 128 plus the signal
+	return (status.Signaled() && status.Signal() == syscall.SIGXCPU) || status.ExitStatus() == 128+int(syscall.SIGXCPU)
+}
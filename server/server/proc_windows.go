@@ -0,0 +1,29 @@
+//go:build windows
+
+package server
+
+import (
+	"os/exec"
+
+	"github.com/joshjennings98/backend-demo/server/v2/types"
+)
+
+// setProcessGroup only kills the command itself on windows as there are no process groups to signal
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.WaitDelay = stopTimeout
+}
+
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		_ = cmd.Process.Kill()
+	}
+}
+
+// withLimits leaves the command as it is since ulimit isn't available on windows, only the timeout and output limits apply
+func withLimits(args []string, _ types.Limits) []string {
+	return args
+}
+
+func cpuLimitExceeded(_ error) bool {
+	return false
+}
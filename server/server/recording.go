@@ -0,0 +1,156 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/joshjennings98/backend-demo/server/v2/types"
+)
+
+const (
+	// terminalCols and terminalRows are the size of the terminal in the browser, which is never resized from the
+	// xterm.js default
+	terminalCols = 80
+	terminalRows = 24
+
+	recordingTimeFormat = "20060102-150405.000"
+)
+
+// castHeader is the first line of an asciicast v2 file
+type castHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Command   string            `json:"command,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// completeRunes returns the length of b without a character that is cut off at the end of it
+func completeRunes(b []byte) int {
+	for i := max(len(b)-utf8.UTFMax+1, 0); i < len(b); i++ {
+		if utf8.RuneStart(b[i]) && !utf8.FullRune(b[i:]) {
+			return i
+		}
+	}
+
+	return len(b)
+}
+
+// castRecorder writes what is sent to the terminal to an asciicast v2 file, each write is an output event timestamped
+// from when the recording started
+type castRecorder struct {
+	mu      sync.Mutex
+	file    *os.File
+	buf     *bufio.Writer
+	start   time.Time
+	pending []byte
+}
+
+func newCastRecorder(path string, header castHeader) (r *castRecorder, err error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		err = fmt.Errorf("could not create recording: %w", err)
+		return
+	}
+
+	r = &castRecorder{file: file, buf: bufio.NewWriter(file), start: time.Now()}
+	header.Version = 2
+	header.Timestamp = r.start.Unix()
+	if err = r.writeLine(header); err != nil {
+		_ = file.Close()
+		r = nil
+	}
+
+	return
+}
+
+func (r *castRecorder) writeLine(v any) (err error) {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+
+	_, err = r.buf.Write(append(line, '\n'))
+	return
+}
+
+func (r *castRecorder) Write(p []byte) (n int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// a character split across writes is held back so it isn't mangled by being encoded in halves
+	r.pending = append(r.pending, p...)
+	if end := completeRunes(r.pending); end > 0 {
+		elapsed := time.Since(r.start).Seconds()
+		if err = r.writeLine([]any{elapsed, "o", string(r.pending[:end])}); err != nil {
+			return
+		}
+		r.pending = r.pending[end:]
+	}
+
+	n = len(p)
+	return
+}
+
+// Close writes anything held back and closes the file
+func (r *castRecorder) Close() (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.pending) > 0 {
+		err = r.writeLine([]any{time.Since(r.start).Seconds(), "o", string(r.pending)})
+		r.pending = nil
+	}
+
+	if flushErr := r.buf.Flush(); err == nil {
+		err = flushErr
+	}
+	if closeErr := r.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return
+}
+
+// castHeader describes the terminal and command of a slide for a recording of it
+func (c *commandManager) castHeader(slide types.Slide) castHeader {
+	return castHeader{
+		Width:   terminalCols,
+		Height:  terminalRows,
+		Title:   slide.Title,
+		Command: slide.Content,
+		Env:     map[string]string{"SHELL": c.interpreter(slide), "TERM": "xterm-256color"},
+	}
+}
+
+// record starts recording what a slide's command sends to the terminal, it returns nil when recording is turned off
+// or the recording couldn't be created since that shouldn't stop the command from running
+func (c *commandManager) record(slide types.Slide) *castRecorder {
+	dir := c.settings.Record.Dir
+	if dir == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		c.logger.Warn("could not create recordings directory", "dir", dir, "error", err)
+		return nil
+	}
+
+	name := fmt.Sprintf("slide-%02d-%v.cast", slide.ID+1, time.Now().Format(recordingTimeFormat))
+	recording, err := newCastRecorder(filepath.Join(dir, name), c.castHeader(slide))
+	if err != nil {
+		c.logger.Warn("could not record command", "slide", slide.ID, "error", err)
+		return nil
+	}
+
+	c.logger.Info("recording command", "file", recording.file.Name())
+	return recording
+}
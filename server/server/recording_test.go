@@ -0,0 +1,100 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/joshjennings98/backend-demo/server/v2/types"
+)
+
+// readCast reads the header and output events of an asciicast file
+func readCast(t *testing.T, path string) (header castHeader, events [][]any) {
+	t.Helper()
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer func() { _ = file.Close() }()
+
+	scanner := bufio.NewScanner(file)
+	require.True(t, scanner.Scan())
+	require.NoError(t, json.Unmarshal(scanner.Bytes(), &header))
+
+	for scanner.Scan() {
+		var event []any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		events = append(events, event)
+	}
+	require.NoError(t, scanner.Err())
+
+	return
+}
+
+func TestCastRecorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.cast")
+
+	recorder, err := newCastRecorder(path, castHeader{Width: 80, Height: 24, Title: "demo"})
+	require.NoError(t, err)
+
+	_, err = recorder.Write([]byte("hello\n\r"))
+	require.NoError(t, err)
+
+	// a character split across writes
+	_, err = recorder.Write([]byte("caf\xc3"))
+	require.NoError(t, err)
+	_, err = recorder.Write([]byte("\xa9"))
+	require.NoError(t, err)
+
+	_, err = recorder.Write([]byte("\xe2\x9c"))
+	require.NoError(t, err)
+	require.NoError(t, recorder.Close())
+
+	header, events := readCast(t, path)
+	assert.Equal(t, 2, header.Version)
+	assert.Equal(t, 80, header.Width)
+	assert.Equal(t, "demo", header.Title)
+	assert.InDelta(t, time.Now().Unix(), header.Timestamp, 5)
+
+	require.Len(t, events, 4)
+	assert.Equal(t, []any{"o", "hello\n\r"}, events[0][1:])
+	assert.Equal(t, []any{"o", "caf"}, events[1][1:])
+	assert.Equal(t, []any{"o", "é"}, events[2][1:])
+	// an unfinished character is still written when the recording is closed
+	assert.Equal(t, "o", events[3][1])
+	assert.LessOrEqual(t, events[0][0], events[3][0])
+
+	_, err = newCastRecorder(path, castHeader{})
+	assert.ErrorContains(t, err, "could not create recording")
+}
+
+func TestCommandManager_Recording(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "recordings")
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	cm := newCommandManager(logger, types.Settings{Record: types.RecordSettings{Dir: dir}}, nil)
+
+	ws, cleanup := setupWebSocket(t, cm)
+	defer cleanup()
+
+	require.NoError(t, cm.Run(types.Slide{ID: 2, Title: "Greeting", Content: "echo hello", ExecuteContent: []string{"echo hello"}}, ""))
+	readTerminal(t, ws, "hello")
+
+	var recordings []string
+	assert.Eventually(t, func() bool {
+		recordings, _ = filepath.Glob(filepath.Join(dir, "slide-03-*.cast"))
+		return len(recordings) == 1 && !cm.IsRunning()
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Len(t, recordings, 1)
+
+	header, events := readCast(t, recordings[0])
+	assert.Equal(t, castHeader{Version: 2, Width: terminalCols, Height: terminalRows, Timestamp: header.Timestamp, Title: "Greeting", Command: "echo hello", Env: map[string]string{"SHELL": "sh", "TERM": "xterm-256color"}}, header)
+	require.NotEmpty(t, events)
+	assert.Equal(t, []any{"o", "hello\n\r"}, events[0][1:])
+}
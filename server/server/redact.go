@@ -0,0 +1,195 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joshjennings98/backend-demo/server/v2/types"
+)
+
+const (
+	redactMask = "********"
+	// minSecretLength stops short values, such as a port number in a .env file, from masking every number in the output
+	minSecretLength = 4
+	// redactHoldSize is how much of a line without a newline is held back in case it contains the start of a secret
+	redactHoldSize = 4096
+	// redactOverlap is the longest secret that is still masked when it is split across a line longer than redactHoldSize
+	redactOverlap = 256
+	// redactIdleFlush is how long to wait for the rest of a line, such as a prompt, before showing what there is of it
+	redactIdleFlush = 50 * time.Millisecond
+)
+
+// secretKeyRegex matches the names of environment variables that hold secrets, their values are masked without being
+// listed in the redact settings
+var secretKeyRegex = regexp.MustCompile(`(?i)(secret|token|passw(or)?d|passphrase|credential|api_?key|private_?key|access_?key|auth)`)
+
+// redactor masks secrets, a nil redactor leaves everything as it is
+type redactor struct {
+	regex *regexp.Regexp
+}
+
+// newRedactor builds a single regular expression from the values of the environment variables and env files, and the patterns.
+// Environment variables are looked up in the settings before the server's environment, and those in the settings, which
+// include the env_file, are masked anyway when their names look like they hold secrets.
+func newRedactor(settings types.Settings) (r *redactor, err error) {
+	var secrets []string
+	for _, key := range settings.Redact.Env {
+		if value, ok := settings.Env[key]; ok {
+			secrets = append(secrets, value)
+		} else {
+			secrets = append(secrets, os.Getenv(key))
+		}
+	}
+
+	for key, value := range settings.Env {
+		if secretKeyRegex.MatchString(key) {
+			secrets = append(secrets, value)
+		}
+	}
+
+	for _, path := range settings.Redact.EnvFiles {
+		env, loadErr := loadDotEnv(path)
+		if loadErr != nil {
+			err = loadErr
+			return
+		}
+		for _, value := range env {
+			secrets = append(secrets, value)
+		}
+	}
+
+	secrets = slices.DeleteFunc(secrets, func(secret string) bool { return len(secret) < minSecretLength })
+	// longest first so a secret containing another is masked completely
+	slices.SortFunc(secrets, func(a, b string) int { return len(b) - len(a) })
+	secrets = slices.Compact(secrets)
+
+	var alternatives []string
+	for _, secret := range secrets {
+		alternatives = append(alternatives, regexp.QuoteMeta(secret))
+	}
+
+	var errs []error
+	for _, pattern := range settings.Redact.Patterns {
+		if _, compileErr := regexp.Compile(pattern); compileErr != nil {
+			errs = append(errs, fmt.Errorf("invalid redact pattern '%v': %w", pattern, compileErr))
+			continue
+		}
+		alternatives = append(alternatives, "(?:"+pattern+")")
+	}
+
+	if err = errors.Join(errs...); err != nil || len(alternatives) == 0 {
+		return
+	}
+
+	r = &redactor{regex: regexp.MustCompile(strings.Join(alternatives, "|"))}
+	return
+}
+
+func (r *redactor) Redact(s string) string {
+	if r == nil {
+		return s
+	}
+
+	return r.regex.ReplaceAllLiteralString(s, redactMask)
+}
+
+// Writer masks secrets in what is written to w. Output is redacted a line at a time so that a secret split across writes
+// is still masked, a partial line is written once the command has been quiet for a moment or when the writer is flushed.
+func (r *redactor) Writer(w io.Writer) *redactWriter {
+	return &redactWriter{w: w, r: r}
+}
+
+type redactWriter struct {
+	w       io.Writer
+	r       *redactor
+	mu      sync.Mutex
+	pending []byte
+	timer   *time.Timer
+	closed  bool
+}
+
+func (rw *redactWriter) Write(p []byte) (n int, err error) {
+	if rw.r == nil {
+		return rw.w.Write(p)
+	}
+
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	rw.pending = append(rw.pending, p...)
+
+	// everything up to the last line break can be redacted now
+	end := 0
+	if i := strings.LastIndexAny(string(rw.pending), "\r\n"); i >= 0 {
+		end = i + 1
+	}
+	if len(rw.pending)-end > redactHoldSize {
+		end = rw.splitLongLine(end)
+	}
+
+	if err = rw.emit(end); err != nil {
+		return
+	}
+
+	if len(rw.pending) > 0 && !rw.closed {
+		if rw.timer == nil {
+			rw.timer = time.AfterFunc(redactIdleFlush, func() { _ = rw.Flush() })
+		} else {
+			rw.timer.Reset(redactIdleFlush)
+		}
+	}
+
+	n = len(p)
+	return
+}
+
+// splitLongLine finds where to split a line that is too long to hold, keeping back enough for a secret that starts
+// near the end and not splitting any secret that has already been matched
+func (rw *redactWriter) splitLongLine(start int) (end int) {
+	end = len(rw.pending) - redactOverlap
+	for _, match := range rw.r.regex.FindAllIndex(rw.pending[start:], -1) {
+		if start+match[0] < end && start+match[1] > end {
+			end = start + match[0]
+		}
+	}
+
+	return
+}
+
+// emit redacts and writes the pending output up to end
+func (rw *redactWriter) emit(end int) (err error) {
+	if end == 0 {
+		return
+	}
+
+	redacted := rw.r.Redact(string(rw.pending[:end]))
+	rw.pending = slices.Delete(rw.pending, 0, end)
+	_, err = io.WriteString(rw.w, redacted)
+	return
+}
+
+// Flush writes any partial line that is being held back
+func (rw *redactWriter) Flush() (err error) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	return rw.emit(len(rw.pending))
+}
+
+// Close flushes the writer and stops it writing anything later
+func (rw *redactWriter) Close() (err error) {
+	rw.mu.Lock()
+	rw.closed = true
+	if rw.timer != nil {
+		rw.timer.Stop()
+	}
+	rw.mu.Unlock()
+
+	return rw.Flush()
+}
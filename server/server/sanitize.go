@@ -0,0 +1,43 @@
+package server
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+)
+
+var (
+	languageClassRegex = regexp.MustCompile(`^language-[\w+-]+$`)
+	dimensionRegex     = regexp.MustCompile(`^\d+(%|px)?$`)
+)
+
+// iframeSourceRegex matches http and https URLs on exactly one of the hosts, which can include a port
+func iframeSourceRegex(hosts []string) *regexp.Regexp {
+	quoted := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		quoted = append(quoted, regexp.QuoteMeta(strings.ToLower(host)))
+	}
+
+	return regexp.MustCompile(fmt.Sprintf(`(?i)^https?://(?:%v)(?:[/?#]|$)`, strings.Join(quoted, "|")))
+}
+
+// newSanitizer returns the allowlist used in safe mode, it keeps the markdown formatting, images and media but drops
+// scripts, styles, event handlers and iframes from anywhere other than the hosts.
+func newSanitizer(iframeHosts []string) *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+
+	p.AllowAttrs("class").Matching(languageClassRegex).OnElements("code")
+	p.AllowAttrs("src", "poster").OnElements("video", "audio")
+	p.AllowAttrs("controls", "loop", "muted", "autoplay", "playsinline").OnElements("video", "audio")
+	p.AllowAttrs("width", "height").Matching(dimensionRegex).OnElements("video", "iframe")
+	p.AllowAttrs("src", "type").OnElements("source")
+
+	if len(iframeHosts) > 0 {
+		p.AllowAttrs("src").Matching(iframeSourceRegex(iframeHosts)).OnElements("iframe")
+		p.AllowAttrs("title", "allowfullscreen").OnElements("iframe")
+	}
+
+	return p
+}
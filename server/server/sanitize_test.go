@@ -0,0 +1,40 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitizer(t *testing.T) {
+	sanitizer := newSanitizer([]string{"www.youtube.com", "localhost:3000"})
+
+	testCases := []struct {
+		name     string
+		html     string
+		expected string
+	}{
+		{name: "Markdown", html: `<h1>Title</h1><p><strong>bold</strong> <a href="https://example.com">link</a></p>`, expected: `<h1>Title</h1><p><strong>bold</strong> <a href="https://example.com" rel="nofollow">link</a></p>`},
+		{name: "Code block", html: `<pre><code class="language-go">fmt.Println()</code></pre>`, expected: `<pre><code class="language-go">fmt.Println()</code></pre>`},
+		{name: "Script", html: `<p>hi</p><script>alert(1)</script>`, expected: `<p>hi</p>`},
+		{name: "Event handler", html: `<img src="logo.svg" alt="logo" onerror="alert(1)"/>`, expected: `<img src="logo.svg" alt="logo"/>`},
+		{name: "Javascript URL", html: `<a href="javascript:alert(1)">click</a>`, expected: `click`},
+		{name: "Style", html: `<style>body{display:none}</style><p style="color:red">text</p>`, expected: `<p>text</p>`},
+		{name: "Video", html: `<video src="demo.mp4" controls width="640" onplay="alert(1)"></video>`, expected: `<video src="demo.mp4" controls="" width="640"></video>`},
+		{name: "Allowed iframe", html: `<iframe src="https://www.youtube.com/embed/abc" width="560"></iframe>`, expected: `<iframe src="https://www.youtube.com/embed/abc" width="560"></iframe>`},
+		{name: "Allowed iframe with port", html: `<iframe src="http://localhost:3000"></iframe>`, expected: `<iframe src="http://localhost:3000"></iframe>`},
+		{name: "Iframe from other host", html: `<iframe src="https://evil.com/embed"></iframe>`, expected: ``},
+		{name: "Iframe from lookalike host", html: `<iframe src="https://www.youtube.com.evil.com/embed"></iframe>`, expected: ``},
+		{name: "Iframe with javascript", html: `<iframe src="javascript:alert(1)"></iframe>`, expected: ``},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, sanitizer.Sanitize(tc.html))
+		})
+	}
+
+	t.Run("No iframe hosts", func(t *testing.T) {
+		assert.Empty(t, newSanitizer(nil).Sanitize(`<iframe src="https://www.youtube.com/embed/abc"></iframe>`))
+	})
+}
@@ -0,0 +1,299 @@
+package server
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log/slog"
+	"maps"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/joshjennings98/backend-demo/server/v2/types"
+)
+
+// safeShellRegex matches words that don't need quoting in a shell script
+var safeShellRegex = regexp.MustCompile(`^[\w@%+=:,./-]+$`)
+
+// ExportScript writes the commands of the presentation in the commands file as a shell script that can be run after
+// the talk. The commands are written as they are in the commands file, including their line continuations, beneath
+// the text of the slides as comments.
+func ExportScript(logger *slog.Logger, commandsFile string, w io.Writer, opts ...Option) (err error) {
+	srv, err := newServer(logger, 0, commandsFile, append(opts, WithExecutionDisabled())...)
+	if err != nil {
+		return
+	}
+
+	return srv.exportScript(w)
+}
+
+func (s *server) exportScript(w io.Writer) (err error) {
+	if len(s.slides) == 0 {
+		return errors.New("there aren't any slides to export")
+	}
+
+	_, body := splitFrontMatter(string(s.sources[0]))
+	script := scriptWriter{server: s, sources: continuedLines(body), dir: filepath.Dir(s.commandsFile)}
+
+	// the slides that use the presentation's shell run in the script itself, the others are run with their interpreter
+	script.program = strings.Join(strings.Fields(Interpreter(s.settings, types.Slide{})), " ")
+	if _, shell := scriptFlag(strings.Fields(script.program)[0]); !shell {
+		script.program = defaultShell
+	}
+
+	// env only splits the shell's arguments, such as bash -eu, into separate words with -S
+	if strings.Contains(script.program, " ") {
+		fmt.Fprintf(&script.b, "#!/usr/bin/env -S %v\n", script.program)
+	} else {
+		fmt.Fprintf(&script.b, "#!/usr/bin/env %v\n", script.program)
+	}
+	if s.settings.Title != "" {
+		fmt.Fprintf(&script.b, "# %v\n#\n", s.redactor.Redact(s.settings.Title))
+	}
+	fmt.Fprintf(&script.b, "# The commands of %v, run it from the directory the commands file is in.\n", filepath.Base(s.commandsFile))
+	script.b.WriteString("# Each slide's commands run in a subshell of their own, like they do when presenting.\n")
+
+	script.environment()
+	if s.settings.WorkingDir != "" {
+		fmt.Fprintf(&script.b, "\ncd %v || exit\n", shellQuote(script.path(s.settings.WorkingDir)))
+		script.dir = s.settings.WorkingDir
+	}
+
+	if len(s.settings.Setup) > 0 {
+		script.b.WriteString("\n# Setup\n")
+		script.commands(types.Slide{ExecuteContent: s.settings.Setup})
+	}
+
+	for _, slide := range s.slides {
+		script.slide(slide)
+	}
+
+	if len(s.settings.Teardown) > 0 {
+		script.b.WriteString("\n# Teardown\n")
+		script.commands(types.Slide{ExecuteContent: s.settings.Teardown})
+	}
+
+	_, err = io.WriteString(w, script.b.String())
+	return
+}
+
+// scriptWriter builds the shell script of a presentation
+type scriptWriter struct {
+	*server
+	b strings.Builder
+	// sources are the commands that were written with line continuations, dir is where the script runs from and program
+	// is the shell it runs with, along with any arguments
+	sources map[string]string
+	dir     string
+	program string
+}
+
+// environment exports the presentation's environment variables. The .env file is sourced rather than copied into the
+// script, and secrets have to be set when the script is run since they can't be shared.
+func (w *scriptWriter) environment() {
+	env := maps.Clone(w.settings.Env)
+	if path := w.settings.EnvFile; path != "" {
+		// the variables from the file are already in the environment, unless the front matter changed them
+		fileEnv, _ := loadDotEnv(path)
+		maps.DeleteFunc(env, func(key, value string) bool {
+			fileValue, ok := fileEnv[key]
+			return ok && fileValue == value
+		})
+
+		if path = w.path(path); !filepath.IsAbs(path) && !strings.HasPrefix(path, "../") {
+			path = "./" + path
+		}
+		fmt.Fprintf(&w.b, "\nset -a\n. %v || exit\nset +a\n", shellQuote(path))
+	}
+
+	if len(env) > 0 {
+		w.b.WriteString("\n")
+		w.exports(env)
+	}
+}
+
+// exports writes the exports of environment variables, those with secret values have to already be set
+func (w *scriptWriter) exports(env map[string]string) {
+	for _, key := range slices.Sorted(maps.Keys(env)) {
+		if value := env[key]; w.redactor.Redact(value) == value {
+			fmt.Fprintf(&w.b, "export %v=%v\n", key, shellQuote(value))
+		} else {
+			fmt.Fprintf(&w.b, "export %v=\"${%v:?must be set}\"\n", key, key)
+		}
+	}
+}
+
+// slide writes a slide to the script, slides that don't run commands are only written as comments
+func (w *scriptWriter) slide(slide types.Slide) {
+	text := commentLines(slide.Content)
+
+	switch slide.SlideType {
+	case types.SlideTypeCommand:
+		fmt.Fprintf(&w.b, "\n# %v\n", slideLabel(slide.ID, len(w.slides), slide.Title))
+		w.commands(slide)
+	case types.SlideTypePlayback:
+		fmt.Fprintf(&w.b, "\n# %v\n", slideLabel(slide.ID, len(w.slides), slide.Title))
+		fmt.Fprintf(&w.b, "# This slide plays back %v rather than running:\n", w.path(slide.Recording))
+		w.comment(text)
+	default:
+		fmt.Fprintf(&w.b, "\n# %v\n", slideLabel(slide.ID, len(w.slides), ""))
+		if len(text) > 0 {
+			w.b.WriteString("#\n")
+			w.comment(text)
+		}
+	}
+}
+
+// commands writes the commands of a slide in a subshell, in its working directory and with its environment
+func (w *scriptWriter) commands(slide types.Slide) {
+	w.b.WriteString("(\n")
+	if slide.WorkingDir != "" {
+		fmt.Fprintf(&w.b, "cd %v || exit\n", shellQuote(w.path(slide.WorkingDir)))
+	}
+	w.exports(slide.Env)
+
+	interpreter := Interpreter(w.settings, slide)
+	inline := strings.Join(strings.Fields(interpreter), " ") == w.program
+
+	if len(slide.Processes) == 0 {
+		if inline {
+			w.lines(slide.ExecuteContent)
+		} else {
+			w.interpret(interpreter, slide.ExecuteContent, "")
+		}
+	} else {
+		// processes that run together are started in the background, after the lines they share
+		if inline {
+			w.lines(slide.ProcessSetup)
+		} else if len(slide.ProcessSetup) > 0 {
+			w.interpret(interpreter, slide.ProcessSetup, " || exit")
+		}
+
+		for _, process := range slide.Processes {
+			if inline {
+				fmt.Fprintf(&w.b, "%v &\n", w.source(process.Command))
+			} else {
+				w.interpret(interpreter, []string{process.Command}, " &")
+			}
+		}
+		w.b.WriteString("wait\n")
+	}
+
+	w.b.WriteString(")\n")
+}
+
+// lines writes commands as they are in the commands file
+func (w *scriptWriter) lines(commands []string) {
+	for _, command := range commands {
+		fmt.Fprintln(&w.b, w.source(command))
+	}
+}
+
+// interpret writes commands that are run with an interpreter other than the script's shell
+func (w *scriptWriter) interpret(interpreter string, commands []string, suffix string) {
+	lines := make([]string, 0, len(commands))
+	for _, command := range commands {
+		lines = append(lines, w.source(command))
+	}
+
+	args := interpreterArgs(interpreter, strings.Join(lines, "\n"))
+	for i, arg := range args {
+		args[i] = shellQuote(arg)
+	}
+	fmt.Fprintf(&w.b, "%v%v\n", strings.Join(args, " "), suffix)
+}
+
+// source is a command as it was written in the commands file, with secrets redacted since the script is shared
+func (w *scriptWriter) source(command string) string {
+	return w.redactor.Redact(cmp.Or(w.sources[command], command))
+}
+
+// comment writes lines of text as comments
+func (w *scriptWriter) comment(lines []string) {
+	for _, line := range lines {
+		fmt.Fprintf(&w.b, "# %v\n", line)
+	}
+}
+
+// path is a path relative to where the script runs from, if it can be
+func (w *scriptWriter) path(path string) string {
+	if rel, err := filepath.Rel(w.dir, path); err == nil {
+		path = rel
+	}
+
+	return filepath.ToSlash(path)
+}
+
+// continuedLines maps the commands that are split over lines with line continuations in the body of a commands file to
+// how they were written, so that they can be exported as they were written rather than as they are run
+func continuedLines(body string) (sources map[string]string) {
+	sources = map[string]string{}
+
+	// the newlines of the continuations are swapped out so that the lines they join aren't split up
+	joined := continuationRegex.ReplaceAllStringFunc(body, func(continuation string) string {
+		return strings.ReplaceAll(continuation, "\n", "\x00")
+	})
+
+	for line := range strings.SplitSeq(joined, "\n") {
+		if !strings.Contains(line, "\x00") {
+			continue
+		}
+
+		source := strings.ReplaceAll(line, "\x00", "\n")
+		line = continuationRegex.ReplaceAllString(source, "")
+		command, ok := commandText(line)
+		if !ok {
+			continue
+		}
+
+		// the continuations are after the $ at the start of the line
+		if source, ok = strings.CutPrefix(source, strings.TrimSuffix(line, command)); ok {
+			if _, found := sources[command]; !found {
+				sources[command] = source
+			}
+		}
+	}
+
+	return
+}
+
+// commandText is the command on a line of a command slide, without the $ or name in front of it
+func commandText(line string) (command string, ok bool) {
+	if match := processRegex.FindStringSubmatch(line); match != nil {
+		return match[2], true
+	}
+
+	for _, prefix := range []string{"$! ", "$ "} {
+		if command, ok = strings.CutPrefix(line, prefix); ok {
+			return
+		}
+	}
+
+	return
+}
+
+// commentLines are the lines of text in the HTML of a slide, without any markup
+func commentLines(content string) (lines []string) {
+	text := html.UnescapeString(tagRegex.ReplaceAllString(content, ""))
+	for line := range strings.SplitSeq(text, "\n") {
+		if line = strings.TrimRightFunc(line, unicode.IsSpace); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return
+}
+
+// shellQuote quotes a word for a shell script if it needs to be
+func shellQuote(word string) string {
+	if safeShellRegex.MatchString(word) {
+		return word
+	}
+
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
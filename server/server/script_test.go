@@ -0,0 +1,174 @@
+package server
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportScript(t *testing.T) {
+	dir := t.TempDir()
+	commands := filepath.Join(dir, "commands.txt")
+	require.NoError(t, os.WriteFile(commands, []byte(`---
+title: Scripted
+shell: bash
+env_file: .env
+env:
+  GREETING: hello world
+  TOKEN: s3cret-value
+redact:
+  env: [TOKEN]
+setup:
+  - docker compose up -d
+teardown:
+  - docker compose down
+---
+
+# Intro
+
+Some *text* & more
+
+$ export NAME=demo
+$ echo "$GREETING" \
+    $NAME
+
+<!-- dir: api -->
+<!-- env: STAGE=dev -->
+$! ls
+$ curl -H "Authorization: s3cret-value" \
+    example.com
+
+<!-- shell: python3 -->
+$ print('it\'s')
+
+$ export PORT=8080
+$[server] ./serve \
+  --port $PORT
+$[client] curl localhost:$PORT
+
+<!-- play: deploy.cast -->
+$ ./deploy.sh
+`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".env"), []byte("DB_PASSWORD=hunter2\n"), 0o600))
+
+	var buf bytes.Buffer
+	require.NoError(t, ExportScript(slog.New(slog.NewTextHandler(os.Stdout, nil)), commands, &buf))
+	assert.Equal(t, `#!/usr/bin/env bash
+# Scripted
+#
+# The commands of commands.txt, run it from the directory the commands file is in.
+# Each slide's commands run in a subshell of their own, like they do when presenting.
+
+set -a
+. ./.env || exit
+set +a
+
+export GREETING='hello world'
+export TOKEN="${TOKEN:?must be set}"
+
+# Setup
+(
+docker compose up -d
+)
+
+# Slide 1/7
+#
+# Intro
+
+# Slide 2/7
+#
+# Some text & more
+
+# 3/7: echo "$GREETING"     $NAME
+(
+export NAME=demo
+echo "$GREETING" \
+    $NAME
+)
+
+# 4/7: ls
+(
+cd api || exit
+export STAGE=dev
+ls
+curl -H "Authorization: ********" \
+    example.com
+)
+
+# 5/7: print('it\'s')
+(
+python3 -c 'print('\''it\'\''s'\'')'
+)
+
+# 6/7: [server] ./serve   --port $PORT
+(
+export PORT=8080
+./serve \
+  --port $PORT &
+curl localhost:$PORT &
+wait
+)
+
+# 7/7: ./deploy.sh
+# This slide plays back deploy.cast rather than running:
+# ./deploy.sh
+
+# Teardown
+(
+docker compose down
+)
+`, buf.String())
+}
+
+func TestExportScript_ShellArguments(t *testing.T) {
+	commands := filepath.Join(t.TempDir(), "commands.txt")
+	require.NoError(t, os.WriteFile(commands, []byte("---\nshell: bash  -eu\n---\n$ echo one\n\n<!-- shell: bash -->\n$ echo two\n"), 0o600))
+
+	var buf bytes.Buffer
+	require.NoError(t, ExportScript(slog.New(slog.NewTextHandler(os.Stdout, nil)), commands, &buf))
+	assert.Equal(t, `#!/usr/bin/env -S bash -eu
+# The commands of commands.txt, run it from the directory the commands file is in.
+# Each slide's commands run in a subshell of their own, like they do when presenting.
+
+# 1/2: echo one
+(
+echo one
+)
+
+# 2/2: echo two
+(
+bash -c 'echo two'
+)
+`, buf.String())
+}
+
+func TestExportScript_Errors(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	var buf bytes.Buffer
+	assert.ErrorContains(t, ExportScript(logger, filepath.Join(t.TempDir(), "missing.txt"), &buf), "missing.txt")
+
+	commands := filepath.Join(t.TempDir(), "commands.txt")
+	require.NoError(t, os.WriteFile(commands, []byte("---\ntitle: Empty\n---\n"), 0o600))
+	assert.ErrorContains(t, ExportScript(logger, commands, &buf), "there aren't any slides")
+}
+
+func TestContinuedLines(t *testing.T) {
+	body := "# Intro \\\ncontinued\n\n$ echo one \\\n  two\n$[server] ./serve \\\n\t--port 8080\n$ echo three\n"
+	assert.Equal(t, map[string]string{
+		"echo one   two":        "echo one \\\n  two",
+		"./serve \t--port 8080": "./serve \\\n\t--port 8080",
+	}, continuedLines(body))
+}
+
+func TestShellQuote(t *testing.T) {
+	assert.Equal(t, "api/v1", shellQuote("api/v1"))
+	assert.Equal(t, "'hello world'", shellQuote("hello world"))
+	assert.Equal(t, `'it'\''s'`, shellQuote("it's"))
+	assert.Equal(t, "''", shellQuote(""))
+}
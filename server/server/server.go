@@ -2,21 +2,30 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"embed"
 	"errors"
 	"fmt"
+	stdhtml "html"
+	"io"
 	"log/slog"
+	"maps"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/gorilla/websocket"
+	"github.com/microcosm-cc/bluemonday"
 	"github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/extension"
 	"github.com/yuin/goldmark/renderer/html"
+	"gopkg.in/yaml.v3"
 
 	"github.com/joshjennings98/backend-demo/server/v2/types"
 )
@@ -32,13 +41,35 @@ const (
 	EndpointCommandStart  = "POST /commands/{id}/start"
 	EndpointCommandStatus = "GET  /commands/{id}/status"
 	EndpointCommandStop   = "POST /commands/{id}/stop"
+	EndpointCommandReplay = "POST /commands/{id}/replay"
+	EndpointPlayback      = "POST /commands/{id}/playback"
+	EndpointContents      = "GET  /contents"
+	EndpointSearch        = "GET  /contents/search"
+	EndpointOverview      = "GET  /overview"
+	EndpointSession       = "GET  /session"
+	EndpointAsset         = "GET  /"
+)
+
+const (
+	defaultPort          = 8080
+	defaultAddress       = "localhost"
+	shutdownTimeout      = 10 * time.Second
+	maxTitleLength       = 60
+	frontMatterDelimiter = "---"
 )
 
 var ErrSlideIndexOutOfBounds = errors.New("slide index out of bounds")
 
 var (
-	whiteSpaceRegex = regexp.MustCompile(`^[\s\n\r]*$`)
-	md2html         = goldmark.New(
+	whiteSpaceRegex    = regexp.MustCompile(`^[\s\n\r]*$`)
+	directiveRegex     = regexp.MustCompile(`^<!--\s*([a-z-]+)\s*(?::\s*(.*?))?\s*-->$`)
+	headingRegex       = regexp.MustCompile(`^#{1,6}\s+(.+)$`)
+	tagRegex           = regexp.MustCompile(`<[^>]*>`)
+	altRegex           = regexp.MustCompile(`alt="([^"]*)"`)
+	processRegex       = regexp.MustCompile(`^\$\[([\w.-]+)\] (.+)$`)
+	envAssignmentRegex = regexp.MustCompile(`([A-Za-z_][A-Za-z0-9_]*)=("(?:[^"\\]|\\.)*"|[^\s"]*)`)
+	continuationRegex  = regexp.MustCompile(`\\\s*\n`)
+	md2html            = goldmark.New(
 		goldmark.WithExtensions(extension.GFM),
 		goldmark.WithRendererOptions(
 			html.WithHardWraps(),
@@ -60,10 +91,149 @@ func parseSlide(content string) (output string) {
 	return
 }
 
+// parseDirectives removes slide directives written as HTML comments (e.g. <!-- title: Intro -->)
+// from the start of the content and returns them keyed by name along with the remaining content.
+// Only the leading lines are read so that comments in the slide, such as those in a code block, are kept.
+func parseDirectives(content string) (directives map[string]string, rest string) {
+	directives = map[string]string{}
+
+	lines := strings.Split(content, "\n")
+	for len(lines) > 0 {
+		line := strings.TrimSpace(lines[0])
+		if match := directiveRegex.FindStringSubmatch(line); match != nil {
+			directives[match[1]] = match[2]
+		} else if line != "" {
+			break
+		}
+		lines = lines[1:]
+	}
+
+	rest = strings.TrimSpace(strings.Join(lines, "\n"))
+	return
+}
+
+// parseLimits reads the limits for a slide's command from its directives, e.g. <!-- timeout: 10s --> or <!-- memory: 256MB -->
+func parseLimits(directives map[string]string) (limits types.Limits, err error) {
+	var errs []error
+	if value, ok := directives["timeout"]; ok {
+		limits.Timeout, err = time.ParseDuration(value)
+		errs = append(errs, err)
+	}
+	if value, ok := directives["cpu"]; ok {
+		limits.CPU, err = time.ParseDuration(value)
+		errs = append(errs, err)
+	}
+	if value, ok := directives["memory"]; ok {
+		limits.Memory, err = types.ParseByteSize(value)
+		errs = append(errs, err)
+	}
+	if value, ok := directives["open-files"]; ok {
+		limits.OpenFiles, err = strconv.Atoi(value)
+		errs = append(errs, err)
+	}
+	if value, ok := directives["max-output"]; ok {
+		limits.MaxOutput, err = types.ParseByteSize(value)
+		errs = append(errs, err)
+	}
+
+	err = errors.Join(errs...)
+	return
+}
+
+// parseSwitch reads a directive that turns something on or off, e.g. <!-- steps: on -->
+func parseSwitch(value string) (on bool, err error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "on", "true", "yes":
+		on = true
+	case "off", "false", "no":
+		on = false
+	default:
+		err = fmt.Errorf("%q should be on or off", value)
+	}
+
+	return
+}
+
+// parseEnvDirective reads the environment variables for a slide's command from a directive such as
+// <!-- env: REGION=eu-west-1 GREETING="hello world" -->
+func parseEnvDirective(value string) (env map[string]string, err error) {
+	if strings.TrimSpace(value) == "" {
+		return
+	}
+
+	env = map[string]string{}
+	rest := envAssignmentRegex.ReplaceAllStringFunc(value, func(assignment string) string {
+		match := envAssignmentRegex.FindStringSubmatch(assignment)
+		if unquoted, unquoteErr := strconv.Unquote(match[2]); unquoteErr == nil {
+			match[2] = unquoted
+		}
+		env[match[1]] = match[2]
+		return ""
+	})
+
+	if strings.TrimSpace(rest) != "" {
+		err = fmt.Errorf("'%v' is not KEY=value", strings.TrimSpace(rest))
+		env = nil
+	}
+
+	return
+}
+
+// plainText strips the tags from rendered HTML and collapses its whitespace.
+func plainText(content string) string {
+	text := stdhtml.UnescapeString(tagRegex.ReplaceAllString(content, " "))
+	return strings.Join(strings.Fields(text), " ")
+}
+
+func truncate(text string, length int) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+
+	return strings.TrimSpace(string(runes[:length-1])) + "…"
+}
+
+// deriveTitle picks a title for a slide that doesn't set one explicitly.
+// Text slides use their first heading or first line, code blocks their first line of code
+// and command slides their first displayed command.
+func deriveTitle(content string, slide types.Slide) (title string) {
+	lines := strings.Split(content, "\n")
+
+	switch slide.SlideType {
+	case types.SlideTypeCommand, types.SlideTypePlayback:
+		title, _, _ = strings.Cut(slide.Content, "\n")
+	case types.SlideTypeCodeblock:
+		for _, line := range lines[1:] {
+			if strings.TrimSpace(line) != "" && !strings.HasPrefix(line, "```") {
+				title = line
+				break
+			}
+		}
+	default:
+		title = lines[0]
+		for _, line := range lines {
+			if match := headingRegex.FindStringSubmatch(line); match != nil {
+				title = match[1]
+				break
+			}
+		}
+
+		rendered := parseSlide(title)
+		title = plainText(rendered)
+		if match := altRegex.FindStringSubmatch(rendered); title == "" && match != nil {
+			title = stdhtml.UnescapeString(match[1])
+		}
+	}
+
+	return truncate(strings.TrimSpace(title), maxTitleLength)
+}
+
 // parseCommandSlide parses a multi-line command block.
 // Returns displayContent (visible lines) and executeContent (all commands to run).
 // Lines with $! are visible, the last $ line is always visible.
 // All $ and $! lines are executed.
+// Lines with $[name] run together and are always visible, with their name.
 func parseCommandSlide(content string) (displayContent []string, executeContent []string) {
 	lines := strings.Split(content, "\n")
 	lines = slices.DeleteFunc(lines, func(line string) bool {
@@ -76,6 +246,10 @@ func parseCommandSlide(content string) (displayContent []string, executeContent
 			cmd := strings.TrimPrefix(line, "$! ")
 			displayContent = append(displayContent, cmd)
 			executeContent = append(executeContent, cmd)
+		case processRegex.MatchString(line):
+			match := processRegex.FindStringSubmatch(line)
+			displayContent = append(displayContent, fmt.Sprintf("[%v] %v", match[1], match[2]))
+			executeContent = append(executeContent, match[2])
 		case strings.HasPrefix(line, "$ "):
 			cmd := strings.TrimPrefix(line, "$ ")
 			executeContent = append(executeContent, cmd)
@@ -88,12 +262,50 @@ func parseCommandSlide(content string) (displayContent []string, executeContent
 	return
 }
 
+// parseSteps splits the lines of a step-through slide into its steps, the hidden lines run with the line shown after
+// them, following the same rules as parseCommandSlide
+func parseSteps(content string) (steps []types.Step) {
+	lines := slices.DeleteFunc(strings.Split(content, "\n"), func(line string) bool {
+		return strings.TrimSpace(line) == ""
+	})
+
+	var hidden []string
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "$! "):
+			steps = append(steps, types.Step{Hidden: hidden, Command: strings.TrimPrefix(line, "$! ")})
+			hidden = nil
+		case strings.HasPrefix(line, "$ ") && i == len(lines)-1:
+			steps = append(steps, types.Step{Hidden: hidden, Command: strings.TrimPrefix(line, "$ ")})
+			hidden = nil
+		case strings.HasPrefix(line, "$ "):
+			hidden = append(hidden, strings.TrimPrefix(line, "$ "))
+		}
+	}
+
+	// hidden lines that aren't followed by one that is shown still run, as a step of their own
+	if len(hidden) > 0 {
+		steps = append(steps, types.Step{Hidden: hidden})
+	}
+
+	return
+}
+
 type server struct {
-	port           int
-	slides         []types.Slide
-	commandsFile   string
+	settings     types.Settings
+	upgrader     websocket.Upgrader
+	auth         *authenticator
+	sanitizer    *bluemonday.Policy
+	redactor     *redactor
+	slides       []types.Slide
+	commandsFile string
+	// sources are the contents of the commands file and its .env file as they were read
+	sources        [][]byte
 	commandManager ICommandManager
-	logger         *slog.Logger
+	// session records the presentation when it is being recorded, and replay is the recorded session being replayed
+	session *sessionRecorder
+	replay  *session
+	logger  *slog.Logger
 }
 
 func (s *server) GetSlide(idx int) (slide types.Slide, err error) {
@@ -110,10 +322,37 @@ func (s *server) GetSlideCount() int {
 	return len(s.slides)
 }
 
+func (s *server) GetSlideTitles() (titles []string) {
+	for _, slide := range s.slides {
+		titles = append(titles, slide.Title)
+	}
+
+	return
+}
+
+// SearchSlides returns the slides whose title, text or commands contain every word in the query.
+// The hidden lines of command slides are only searched with commands, since only the presenter can see them.
+func (s *server) SearchSlides(query string, commands bool) (results []types.Slide) {
+	terms := strings.Fields(strings.ToLower(query))
+	for _, slide := range s.slides {
+		fields := []string{slide.Title, plainText(slide.Content)}
+		if commands {
+			fields = append(fields, slide.Commands...)
+		}
+
+		text := strings.ToLower(strings.Join(fields, "\n"))
+		if !slices.ContainsFunc(terms, func(term string) bool { return !strings.Contains(text, term) }) {
+			results = append(results, slide)
+		}
+	}
+
+	return
+}
+
 func isCommand(content string) (isCommand bool) {
 	for line := range strings.SplitSeq(content, "\n") {
 		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "$ ") || strings.HasPrefix(line, "$! ") {
+		if strings.HasPrefix(line, "$ ") || strings.HasPrefix(line, "$! ") || processRegex.MatchString(line) {
 			isCommand = true
 			return
 		}
@@ -123,7 +362,9 @@ func isCommand(content string) (isCommand bool) {
 }
 
 func (s *server) ParseSlide(content string) {
-	if whiteSpaceRegex.MatchString(content) {
+	directives, content := parseDirectives(content)
+	recording, isPlayback := directives["play"]
+	if whiteSpaceRegex.MatchString(content) && !isPlayback {
 		return
 	}
 
@@ -132,6 +373,9 @@ func (s *server) ParseSlide(content string) {
 	}
 
 	switch {
+	case isPlayback:
+		slide.SlideType = types.SlideTypePlayback
+		s.parsePlaybackSlide(&slide, content, recording, directives)
 	case strings.HasPrefix(content, "```"):
 		slide.SlideType = types.SlideTypeCodeblock
 		slide.Content = parseSlide(content)
@@ -140,14 +384,98 @@ func (s *server) ParseSlide(content string) {
 		displayContent, executeContent := parseCommandSlide(content)
 		slide.Content = strings.Join(displayContent, "\n")
 		slide.ExecuteContent = executeContent
+		slide.ProcessSetup, slide.Processes = parseProcesses(content)
 	default:
 		slide.SlideType = types.SlideTypePlain
 		slide.Content = parseSlide(content)
 	}
 
+	// commands are always shown as text so only the rendered markdown needs sanitizing
+	if s.sanitizer != nil && !hasTerminal(slide.SlideType) {
+		slide.Content = s.sanitizer.Sanitize(slide.Content)
+	}
+
+	slide.Assets = collectAssets(slide.Content)
+
+	if slide.SlideType == types.SlideTypeCommand {
+		var err error
+		if slide.Limits, err = parseLimits(directives); err != nil {
+			s.logger.Warn("ignoring invalid limits", "slide", slide.ID, "error", err)
+		}
+		if slide.Env, err = parseEnvDirective(directives["env"]); err != nil {
+			s.logger.Warn("ignoring invalid environment variables", "slide", slide.ID, "error", err)
+		}
+		if dir := directives["dir"]; dir != "" {
+			slide.WorkingDir = s.resolveDir(dir)
+		}
+		slide.Shell = directives["shell"]
+		if value, ok := directives["steps"]; ok {
+			if slide.Steps, err = parseSwitch(value); err != nil {
+				s.logger.Warn("ignoring invalid steps setting", "slide", slide.ID, "error", err)
+			}
+			// commands that run together are started at once so they can't be stepped through
+			if slide.Steps && len(slide.Processes) > 0 {
+				s.logger.Warn("ignoring steps setting for commands that run together", "slide", slide.ID)
+				slide.Steps = false
+			}
+			if slide.Steps {
+				slide.StepCommands = parseSteps(content)
+			}
+		}
+		if value, ok := directives["typewriter"]; ok {
+			if slide.Typewriter, err = parseTypewriter(value, s.settings.Typewriter); err != nil {
+				s.logger.Warn("ignoring invalid typewriter setting", "slide", slide.ID, "error", err)
+			}
+		}
+	}
+
+	slide.Title = directives["title"]
+	if slide.Title == "" {
+		slide.Title = deriveTitle(content, slide)
+	}
+
+	// secrets are only masked in what is shown, the commands still run with them
+	slide.Content = s.redactor.Redact(slide.Content)
+	slide.Title = s.redactor.Redact(slide.Title)
+	for _, line := range slide.ExecuteContent {
+		slide.Commands = append(slide.Commands, s.redactor.Redact(line))
+	}
+
 	s.slides = append(s.slides, slide)
 }
 
+// parsePlaybackSlide sets the recording a playback slide replays and what is shown above the terminal, which is the
+// slide's commands or text, or the command from the recording if the slide doesn't have any
+func (s *server) parsePlaybackSlide(slide *types.Slide, content, recording string, directives map[string]string) {
+	slide.Recording = recording
+	if !filepath.IsAbs(recording) {
+		slide.Recording = filepath.Join(filepath.Dir(s.commandsFile), recording)
+	}
+
+	var err error
+	if slide.Playback, err = parsePlayback(directives); err != nil {
+		s.logger.Warn("ignoring invalid playback settings", "slide", slide.ID, "error", err)
+	}
+
+	// the recording might not have been made yet so it only needs to exist when the slide is played
+	header, _, err := loadCast(slide.Recording)
+	if err != nil {
+		s.logger.Warn("could not load recording for playback slide", "slide", slide.ID, "error", err)
+	}
+
+	switch {
+	case isCommand(content):
+		displayContent, _ := parseCommandSlide(content)
+		slide.Content = strings.Join(displayContent, "\n")
+	case strings.TrimSpace(content) != "":
+		slide.Content = strings.TrimSpace(content)
+	case header.Command != "":
+		slide.Content = header.Command
+	default:
+		slide.Content = filepath.Base(recording)
+	}
+}
+
 func (s *server) ParseSlides(contents []string) {
 	for _, line := range contents {
 		trimmed := strings.TrimSpace(line)
@@ -157,15 +485,121 @@ func (s *server) ParseSlides(contents []string) {
 	}
 }
 
-func (s *server) SplitContent(commandsFile string) (slideContent []string, err error) {
-	contents, err := os.ReadFile(commandsFile)
+// splitFrontMatter separates an optional YAML block delimited by --- lines at the top of a commands file from the slides.
+func splitFrontMatter(contents string) (frontMatter, body string) {
+	lines := strings.SplitAfter(contents, "\n")
+	if strings.TrimSpace(lines[0]) != frontMatterDelimiter {
+		body = contents
+		return
+	}
+
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == frontMatterDelimiter {
+			frontMatter = strings.Join(lines[1:i], "")
+			body = strings.Join(lines[i+1:], "")
+			return
+		}
+	}
+
+	// an unterminated block is just a slide
+	body = contents
+	return
+}
+
+func (s *server) GetSettings() types.Settings {
+	return s.settings
+}
+
+// GetSources returns the contents of the commands file, followed by its .env file if it has one, as they were read
+// when the presentation was loaded. They are everything that decides what the presentation runs.
+func (s *server) GetSources() [][]byte {
+	return s.sources
+}
+
+// resolveDir resolves a slide's working directory relative to the presentation's, or the commands file if it doesn't have one
+func (s *server) resolveDir(dir string) string {
+	if filepath.IsAbs(dir) {
+		return dir
+	}
+
+	base := s.settings.WorkingDir
+	if base == "" {
+		base = filepath.Dir(s.commandsFile)
+	}
+
+	return filepath.Join(base, dir)
+}
+
+// readCommandsFile reads the contents of a commands file, which are read once so that what is presented is exactly
+// what was allowed to run
+func readCommandsFile(commandsFile string) (contents []byte, err error) {
+	contents, err = os.ReadFile(commandsFile)
 	if err != nil {
 		err = fmt.Errorf("could not read content from '%v': %w", commandsFile, err)
+	}
+
+	return
+}
+
+func (s *server) LoadSettings(commandsFile string) (settings types.Settings, err error) {
+	contents, err := readCommandsFile(commandsFile)
+	if err != nil {
+		return
+	}
+
+	return parseSettings(commandsFile, contents)
+}
+
+// parseSettings reads the settings from the front matter of the contents of a commands file
+func parseSettings(commandsFile string, contents []byte) (settings types.Settings, err error) {
+	frontMatter, _ := splitFrontMatter(string(contents))
+
+	decoder := yaml.NewDecoder(strings.NewReader(frontMatter))
+	decoder.KnownFields(true)
+	if err = decoder.Decode(&settings); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = nil
+			return
+		}
+		err = fmt.Errorf("could not parse front matter of '%v': %w", commandsFile, err)
 		return
 	}
 
+	if fallback := settings.Fallback; fallback.Dir == "" && (fallback.Record || fallback.Replay != "" && fallback.Replay != FallbackOff) {
+		settings.Fallback.Dir = defaultFallbackDir
+	}
+
+	// paths in the front matter are relative to the commands file
+	paths := []*string{&settings.WorkingDir, &settings.EnvFile, &settings.TLS.Cert, &settings.TLS.Key, &settings.Record.Dir, &settings.Record.Session, &settings.Fallback.Dir, &settings.Transcript.File}
+	for i := range settings.Redact.EnvFiles {
+		paths = append(paths, &settings.Redact.EnvFiles[i])
+	}
+
+	for _, path := range paths {
+		if *path != "" && !filepath.IsAbs(*path) {
+			*path = filepath.Join(filepath.Dir(commandsFile), *path)
+		}
+	}
+
+	return
+}
+
+func (s *server) SplitContent(commandsFile string) (slideContent []string, err error) {
+	contents, err := readCommandsFile(commandsFile)
+	if err != nil {
+		return
+	}
+
+	slideContent = splitContent(contents)
+	return
+}
+
+// splitContent splits the contents of a commands file into the content of each slide
+func splitContent(contents []byte) (slideContent []string) {
+	_, body := splitFrontMatter(string(contents))
+
 	// handle line continuations (\ at end of line)
-	contentStr := regexp.MustCompile(`\\\s*\n`).ReplaceAllString(string(contents), "")
+	contentStr := continuationRegex.ReplaceAllString(body, "")
 
 	// split by double newlines to get slide blocks
 	slideContent = strings.Split(contentStr, "\n\n")
@@ -173,29 +607,230 @@ func (s *server) SplitContent(commandsFile string) (slideContent []string, err e
 	return
 }
 
-func NewServer(logger *slog.Logger, port int, commandsFile string) (s IPresentationServer, err error) {
-	if port == 0 {
-		port = 8080
+// NewServer creates a presentation server for the commands file.
+// A non-zero port and the options override the settings from the front matter of the commands file.
+func NewServer(logger *slog.Logger, port int, commandsFile string, opts ...Option) (s IPresentationServer, err error) {
+	srv, err := newServer(logger, port, commandsFile, opts...)
+	if err != nil {
+		return
+	}
+
+	s = srv
+	return
+}
+
+func newServer(logger *slog.Logger, port int, commandsFile string, opts ...Option) (srv *server, err error) {
+	srv = &server{
+		logger:       logger,
+		commandsFile: commandsFile,
+	}
+
+	contents, err := readCommandsFile(commandsFile)
+	if err != nil {
+		return
+	}
+	srv.sources = [][]byte{contents}
+
+	srv.settings, err = parseSettings(commandsFile, contents)
+	if err != nil {
+		return
+	}
+
+	if err = srv.configure(port, opts); err != nil {
+		return
+	}
+
+	srv.ParseSlides(splitContent(contents))
+
+	// there is nothing to run the commands with when execution is disabled
+	if !srv.settings.ExecutionDisabled {
+		if err = srv.commandManager.CheckInterpreters(srv.slides); err != nil {
+			return
+		}
+	}
+
+	// a presentation that isn't trusted to run its commands isn't trusted to write files either
+	if path := srv.settings.Record.Session; path != "" && !srv.settings.ExecutionDisabled {
+		srv.session = newSessionRecorder(path, srv.settings, srv.slides)
+		srv.commandManager.SetTerminalRecorder(srv.session)
+	}
+
+	return
+}
+
+// NewReplayServer creates a server that plays a recorded session back in the browser. Files embedded in the slides
+// are served from the directory of the session file.
+func NewReplayServer(logger *slog.Logger, port int, sessionFile string, opts ...Option) (s IPresentationServer, err error) {
+	recorded, err := loadSession(sessionFile)
+	if err != nil {
+		return
+	}
+
+	srv := &server{
+		logger:       logger,
+		commandsFile: sessionFile,
+		replay:       &recorded,
+		settings: types.Settings{
+			Title:    recorded.Settings.Title,
+			Theme:    recorded.Settings.Theme,
+			Terminal: recorded.Settings.Terminal,
+			Assets:   recorded.Settings.Assets,
+		},
+	}
+
+	for i, slide := range recorded.Slides {
+		srv.slides = append(srv.slides, types.Slide{ID: i, Title: slide.Title, Content: slide.Content, SlideType: slide.Type, Assets: slide.Assets})
+	}
+
+	// nothing is run when replaying, what was shown in the terminal is played back by the browser
+	opts = append(opts, func(settings *types.Settings) {
+		settings.ExecutionDisabled = true
+		settings.Replay = true
+	})
+	if err = srv.configure(port, opts); err != nil {
+		return
+	}
+
+	s = srv
+	return
+}
+
+// configure applies the options and a non-zero port to the settings, checks them and sets up what they need
+func (s *server) configure(port int, opts []Option) (err error) {
+	for _, opt := range opts {
+		opt(&s.settings)
+	}
+
+	if port != 0 {
+		s.settings.Port = port
+	}
+	if s.settings.Port == 0 {
+		s.settings.Port = defaultPort
+	}
+	if s.settings.Listen.Address == "" {
+		s.settings.Listen.Address = defaultAddress
+	}
+
+	if (s.settings.TLS.Cert == "") != (s.settings.TLS.Key == "") {
+		err = errors.New("both a TLS certificate and key must be provided")
+		return
+	}
+
+	switch replay := s.settings.Fallback.Replay; replay {
+	case "", FallbackOffer, FallbackAuto, FallbackOff:
+	default:
+		err = fmt.Errorf("fallback replay must be %v, %v or %v, not '%v'", FallbackOffer, FallbackAuto, FallbackOff, replay)
+		return
+	}
+
+	switch format := s.settings.Transcript.Format; format {
+	case "", TranscriptText, TranscriptJSON:
+	default:
+		err = fmt.Errorf("transcript format must be %v or %v, not '%v'", TranscriptText, TranscriptJSON, format)
+		return
+	}
+
+	s.upgrader = newUpgrader(false, s.settings.Listen.AllowedOrigins)
+	if s.settings.Auth {
+		if s.auth, err = newAuthenticator(s.settings.TLS.Enabled()); err != nil {
+			return
+		}
+	}
+
+	if s.settings.EnvFile != "" {
+		var env map[string]string
+		var contents []byte
+		if env, contents, err = readDotEnv(s.settings.EnvFile); err != nil {
+			return
+		}
+		s.sources = append(s.sources, contents)
+
+		// variables set in the front matter take precedence over the file
+		maps.Copy(env, s.settings.Env)
+		s.settings.Env = env
+	}
+
+	if s.redactor, err = newRedactor(s.settings); err != nil {
+		return
+	}
+
+	s.commandManager = newCommandManager(s.logger, s.settings, s.redactor)
+	if s.settings.Safe.Enabled {
+		s.sanitizer = newSanitizer(s.settings.Safe.IframeHosts)
+	}
+
+	return
+}
+
+// runHook runs the setup or teardown commands from the front matter, logging their output
+func (s *server) runHook(ctx context.Context, name string, commands []string) (err error) {
+	if len(commands) == 0 {
+		return
 	}
 
-	s = &server{
-		port:           port,
-		logger:         logger,
-		commandsFile:   commandsFile,
-		commandManager: newCommandManager(logger),
+	if s.settings.ExecutionDisabled {
+		s.logger.Warn("skipping commands since execution is disabled", "commands", name)
+		return
 	}
 
-	content, err := s.SplitContent(commandsFile)
+	s.logger.Info("running commands", "commands", name)
+
+	output := newLogWriter(s.logger, name)
+	err = s.commandManager.Exec(ctx, commands, output)
+	output.Flush()
 	if err != nil {
-		err = fmt.Errorf("could not split content of file '%v': %w", commandsFile, err)
+		err = fmt.Errorf("%v commands failed: %w", name, err)
 		return
 	}
 
-	s.ParseSlides(content)
+	s.logger.Info("commands completed", "commands", name)
 	return
 }
 
-func (s *server) Start(ctx context.Context) error {
+// listen creates the listener for the server, either a unix domain socket or a TCP port on the configured address
+func (s *server) listen() (listener net.Listener, err error) {
+	socket := s.settings.Listen.Socket
+	if socket == "" {
+		listener, err = net.Listen("tcp", net.JoinHostPort(s.settings.Listen.Address, strconv.Itoa(s.settings.Port)))
+		return
+	}
+
+	// remove a socket left behind by a previous run that didn't shut down cleanly
+	if info, statErr := os.Stat(socket); statErr == nil && info.Mode()&os.ModeSocket != 0 {
+		if err = os.Remove(socket); err != nil {
+			err = fmt.Errorf("could not remove stale socket '%v': %w", socket, err)
+			return
+		}
+	}
+
+	listener, err = net.Listen("unix", socket)
+	if err != nil {
+		return
+	}
+
+	// only the presenter should be able to connect to the socket
+	if err = os.Chmod(socket, 0o600); err != nil {
+		_ = listener.Close()
+		err = fmt.Errorf("could not set permissions of socket '%v': %w", socket, err)
+	}
+
+	return
+}
+
+func (s *server) presentationURL() string {
+	if s.settings.Listen.Socket != "" {
+		return "unix://" + s.settings.Listen.Socket
+	}
+
+	scheme := "http"
+	if s.settings.TLS.Enabled() {
+		scheme = "https"
+	}
+
+	return fmt.Sprintf("%v://%v/presentation", scheme, net.JoinHostPort(s.settings.Listen.Address, strconv.Itoa(s.settings.Port)))
+}
+
+func (s *server) Start(ctx context.Context) (err error) {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc(EndpointIndex, s.HandlerIndex)
@@ -205,22 +840,77 @@ func (s *server) Start(ctx context.Context) error {
 	mux.HandleFunc(EndpointCommandStart, s.HandlerCommandStart)
 	mux.HandleFunc(EndpointCommandStatus, s.HandlerCommandStatus)
 	mux.HandleFunc(EndpointCommandStop, s.HandlerCommandStop)
+	mux.HandleFunc(EndpointCommandReplay, s.HandlerCommandReplay)
+	mux.HandleFunc(EndpointPlayback, s.HandlerPlayback)
+	mux.HandleFunc(EndpointContents, s.HandlerContents)
+	mux.HandleFunc(EndpointSearch, s.HandlerSearch)
+	mux.HandleFunc(EndpointOverview, s.HandlerOverview)
+	mux.HandleFunc(EndpointSession, s.HandlerSession)
+
+	mux.HandleFunc("GET /static/", http.FileServerFS(staticFS).ServeHTTP)
+	mux.HandleFunc(EndpointAsset, s.HandlerAsset)
 
-	mux.HandleFunc("/static/", http.FileServerFS(staticFS).ServeHTTP)
-	mux.HandleFunc("/", http.FileServer(http.Dir(filepath.Dir(s.commandsFile))).ServeHTTP)
+	tlsConfig, err := s.tlsConfig()
+	if err != nil {
+		return
+	}
 
-	s.logger.Info("server is running", "host", fmt.Sprintf("http://localhost:%v/presentation", s.port))
+	if err = s.runHook(ctx, "setup", s.settings.Setup); err != nil {
+		return
+	}
+
+	defer func() {
+		err = errors.Join(err, s.runHook(context.Background(), "teardown", s.settings.Teardown))
+		// the commands have been stopped so everything they wrote to the terminal is in the session
+		s.saveSession()
+	}()
+
+	listener, err := s.listen()
+	if err != nil {
+		return
+	}
+
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+
+	s.logger.Info("server is running", "host", s.presentationURL())
+	if s.auth != nil {
+		s.logger.Info("open the presenter link to run commands, anyone else can only view the slides", "presenter", s.presentationURL()+"?token="+s.auth.token)
+	}
 
 	server := &http.Server{
-		Addr:              fmt.Sprintf("localhost:%v", s.port),
 		Handler:           mux,
 		ReadHeaderTimeout: 3 * time.Second, // https://deepsource.com/directory/go/issues/GO-S2112
 	}
 
+	serveErr := make(chan error, 1)
 	go func() {
-		<-ctx.Done()
-		_ = server.Shutdown(context.Background())
+		serveErr <- server.Serve(listener)
 	}()
 
-	return server.ListenAndServe()
+	select {
+	case err = <-serveErr:
+		// the server failed without being asked to stop so there are no connections to drain
+		err = errors.Join(err, s.commandManager.Shutdown(context.Background()))
+		return
+	case <-ctx.Done():
+	}
+
+	s.logger.Info("shutting down server")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// drain http requests before stopping commands so that nothing new is started
+	err = errors.Join(
+		server.Shutdown(shutdownCtx),
+		s.commandManager.Shutdown(shutdownCtx),
+	)
+
+	if serveErr := <-serveErr; !errors.Is(serveErr, http.ErrServerClosed) {
+		err = errors.Join(err, serveErr)
+	}
+
+	return
 }
@@ -1,11 +1,18 @@
 package server
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"log/slog"
+	"net"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -43,6 +50,7 @@ func TestNewServer(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, types.Slide{
 			ID:             0,
+			Title:          "this is a presentation",
 			Content:        "<p>this is a <a href=\"http://google.com\">presentation</a></p>\n",
 			ExecuteContent: nil,
 			SlideType:      types.SlideTypePlain,
@@ -52,8 +60,10 @@ func TestNewServer(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, types.Slide{
 			ID:             1,
+			Title:          "echo aaa && sleep 2 && echo bbb",
 			Content:        "echo aaa && sleep 2 && echo bbb",
 			ExecuteContent: []string{"echo aaa && sleep 2 && echo bbb"},
+			Commands:       []string{"echo aaa && sleep 2 && echo bbb"},
 			SlideType:      types.SlideTypeCommand,
 		}, slide1)
 
@@ -61,8 +71,10 @@ func TestNewServer(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, types.Slide{
 			ID:             2,
+			Title:          "echo $TEST",
 			Content:        "echo $TEST",
 			ExecuteContent: []string{"TEST=345", "echo $TEST"},
+			Commands:       []string{"TEST=345", "echo $TEST"},
 			SlideType:      types.SlideTypeCommand,
 		}, slide2)
 
@@ -70,6 +82,7 @@ func TestNewServer(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, types.Slide{
 			ID:             3,
+			Title:          "def main():",
 			Content:        "<pre><code class=\"language-python\">def main():\n    print(&quot;hello world&quot;)\n</code></pre>\n",
 			ExecuteContent: nil,
 			SlideType:      types.SlideTypeCodeblock,
@@ -79,6 +92,7 @@ func TestNewServer(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, types.Slide{
 			ID:             4,
+			Title:          "hyperion",
 			Content:        "<p><img src=\"https://upload.wikimedia.org/wikipedia/en/7/73/Hyperion_cover.jpg\" alt=\"hyperion\" /></p>\n",
 			ExecuteContent: nil,
 			SlideType:      types.SlideTypePlain,
@@ -88,8 +102,10 @@ func TestNewServer(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, types.Slide{
 			ID:             13,
+			Title:          "echo \"visible setup line\"",
 			Content:        "echo \"visible setup line\"\necho \"main command\"",
 			ExecuteContent: []string{"echo \"visible setup line\"", "echo \"main command\""},
+			Commands:       []string{"echo \"visible setup line\"", "echo \"main command\""},
 			SlideType:      types.SlideTypeCommand,
 		}, slide13)
 
@@ -99,7 +115,520 @@ func TestNewServer(t *testing.T) {
 		assert.ErrorIs(t, err, ErrSlideIndexOutOfBounds)
 
 		assert.Equal(t, 14, s.GetSlideCount())
+		assert.Equal(t, types.Settings{Port: 8080, Listen: types.ListenSettings{Address: "localhost"}}, s.GetSettings())
 	})
+
+	t.Run("Front matter", func(t *testing.T) {
+		commands := filepath.Join("..", "testdata", "front-matter.txt")
+
+		s, err := NewServer(slog.New(slog.NewTextHandler(os.Stdout, nil)), 0, commands)
+		require.NoError(t, err)
+
+		assert.Equal(t, types.Settings{
+			Title:      "Front Matter Demo",
+			Theme:      "light",
+			Shell:      "bash",
+			WorkingDir: filepath.Join("..", "testdata"),
+			Env:        map[string]string{"GREETING": "hello"},
+			Terminal:   types.TerminalSettings{FontSize: 18, Scrollback: 500},
+			Port:       9090,
+			Listen: types.ListenSettings{
+				Address:        "0.0.0.0",
+				AllowedOrigins: []string{"https://demo.example.com"},
+			},
+		}, s.GetSettings())
+
+		slideContent, err := s.SplitContent(commands)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"\n# Welcome", "$ echo $GREETING\n"}, slideContent)
+		assert.Equal(t, 2, s.GetSlideCount())
+	})
+
+	t.Run("Port flag overrides front matter", func(t *testing.T) {
+		s, err := NewServer(slog.New(slog.NewTextHandler(os.Stdout, nil)), 1234, filepath.Join("..", "testdata", "front-matter.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, 1234, s.GetSettings().Port)
+	})
+
+	t.Run("Options override front matter", func(t *testing.T) {
+		s, err := NewServer(
+			slog.New(slog.NewTextHandler(os.Stdout, nil)),
+			0,
+			filepath.Join("..", "testdata", "front-matter.txt"),
+			WithListenAddress("192.168.0.10"),
+			WithAllowedOrigins([]string{"http://devbox:9090"}),
+			WithSocket("/tmp/demo.sock"),
+		)
+		require.NoError(t, err)
+		assert.Equal(t, types.ListenSettings{
+			Address:        "192.168.0.10",
+			Socket:         "/tmp/demo.sock",
+			AllowedOrigins: []string{"http://devbox:9090"},
+		}, s.GetSettings().Listen)
+	})
+
+	t.Run("Safe mode", func(t *testing.T) {
+		commands := filepath.Join(t.TempDir(), "commands.txt")
+		require.NoError(t, os.WriteFile(commands, []byte("# Hello <script>alert(1)</script>\n\n$ echo <b>hi</b>\n"), 0o600))
+
+		s, err := NewServer(slog.New(slog.NewTextHandler(os.Stdout, nil)), 0, commands, WithSafeMode([]string{"www.youtube.com"}))
+		require.NoError(t, err)
+		assert.True(t, s.GetSettings().ExecutionDisabled)
+
+		slide, err := s.GetSlide(0)
+		require.NoError(t, err)
+		assert.Equal(t, "<h1>Hello </h1>\n", slide.Content)
+
+		// commands are escaped when rendered so they are left as they are
+		slide, err = s.GetSlide(1)
+		require.NoError(t, err)
+		assert.Equal(t, "echo <b>hi</b>", slide.Content)
+	})
+
+	t.Run("Limits", func(t *testing.T) {
+		commands := filepath.Join(t.TempDir(), "commands.txt")
+		require.NoError(t, os.WriteFile(commands, []byte("---\nlimits:\n  timeout: 30s\n  memory: 512MB\n---\n<!-- timeout: 5s -->\n$ ls -R /\n"), 0o600))
+
+		s, err := NewServer(slog.New(slog.NewTextHandler(os.Stdout, nil)), 0, commands)
+		require.NoError(t, err)
+		assert.Equal(t, types.Limits{Timeout: 30 * time.Second, Memory: 512 << 20}, s.GetSettings().Limits)
+
+		slide, err := s.GetSlide(0)
+		require.NoError(t, err)
+		assert.Equal(t, types.Limits{Timeout: 5 * time.Second}, slide.Limits)
+	})
+
+	t.Run("Redaction", func(t *testing.T) {
+		dir := t.TempDir()
+		commands := filepath.Join(dir, "commands.txt")
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "staging.env"), []byte("API_TOKEN=tok-12345\n"), 0o600))
+		require.NoError(t, os.WriteFile(commands, []byte("---\nredact:\n  env_files: [staging.env]\n---\n$ curl -H \"X-Token: tok-12345\" api\n"), 0o600))
+
+		s, err := NewServer(slog.New(slog.NewTextHandler(os.Stdout, nil)), 0, commands)
+		require.NoError(t, err)
+		assert.Equal(t, []string{filepath.Join(dir, "staging.env")}, s.GetSettings().Redact.EnvFiles)
+
+		slide, err := s.GetSlide(0)
+		require.NoError(t, err)
+		assert.Equal(t, `curl -H "X-Token: ********" api`, slide.Content)
+		assert.Equal(t, `curl -H "X-Token: ********" api`, slide.Title)
+		assert.Equal(t, []string{`curl -H "X-Token: tok-12345" api`}, slide.ExecuteContent)
+		assert.Equal(t, []string{`curl -H "X-Token: ********" api`}, slide.Commands)
+	})
+
+	t.Run("Environment", func(t *testing.T) {
+		dir := t.TempDir()
+		commands := filepath.Join(dir, "commands.txt")
+		require.NoError(t, os.WriteFile(filepath.Join(dir, ".env"), []byte("REGION=eu-west-1\nGREETING=from file\nDB_PASSWORD=hunter22\n"), 0o600))
+		require.NoError(t, os.WriteFile(commands, []byte("---\nenv_file: .env\nenv:\n  GREETING: hello\ndir: demo\n---\n<!-- dir: api -->\n<!-- env: STAGE=dev MESSAGE=\"hello world\" -->\n$ make run\n\n<!-- dir: /tmp -->\n$ ls\n"), 0o600))
+
+		s, err := newServer(slog.New(slog.NewTextHandler(os.Stdout, nil)), 0, commands)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"REGION": "eu-west-1", "GREETING": "hello", "DB_PASSWORD": "hunter22"}, s.GetSettings().Env)
+
+		// the secrets in the env file are masked without being listed in the redact settings
+		assert.Equal(t, "psql -h eu-west-1 -W ********", s.redactor.Redact("psql -h eu-west-1 -W hunter22"))
+
+		slide, err := s.GetSlide(0)
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(dir, "demo", "api"), slide.WorkingDir)
+		assert.Equal(t, map[string]string{"STAGE": "dev", "MESSAGE": "hello world"}, slide.Env)
+
+		slide, err = s.GetSlide(1)
+		require.NoError(t, err)
+		assert.Equal(t, "/tmp", slide.WorkingDir)
+		assert.Nil(t, slide.Env)
+	})
+
+	t.Run("Missing env file", func(t *testing.T) {
+		commands := filepath.Join(t.TempDir(), "commands.txt")
+		require.NoError(t, os.WriteFile(commands, []byte("---\nenv_file: .env\n---\n$ ls\n"), 0o600))
+
+		_, err := NewServer(slog.New(slog.NewTextHandler(os.Stdout, nil)), 0, commands)
+		assert.ErrorContains(t, err, "could not open env file")
+	})
+
+	t.Run("Command directives", func(t *testing.T) {
+		commands := filepath.Join(t.TempDir(), "commands.txt")
+		require.NoError(t, os.WriteFile(commands, []byte("<!-- shell: python3 -->\n$ print('hello')\n\n<!-- typewriter: 20ms -->\n<!-- steps: on -->\n$ ls\n$ pwd\n"), 0o600))
+
+		s, err := NewServer(slog.New(slog.NewTextHandler(os.Stdout, nil)), 0, commands)
+		require.NoError(t, err)
+
+		srv, ok := s.(*server)
+		require.True(t, ok)
+		assert.Equal(t, "python3", srv.slides[0].Shell)
+		assert.Nil(t, srv.slides[0].Typewriter)
+		assert.Empty(t, srv.slides[1].Shell)
+		assert.Equal(t, &types.Typewriter{Enabled: true, Speed: 20 * time.Millisecond}, srv.slides[1].Typewriter)
+		assert.False(t, srv.slides[0].Steps)
+		assert.True(t, srv.slides[1].Steps)
+		assert.Equal(t, []types.Step{{Hidden: []string{"ls"}, Command: "pwd"}}, srv.slides[1].StepCommands)
+	})
+
+	t.Run("Playback slides", func(t *testing.T) {
+		dir := t.TempDir()
+		commands := filepath.Join(dir, "commands.txt")
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "deploy.cast"), []byte(`{"version": 2, "width": 80, "height": 24, "command": "./deploy.sh"}`+"\n"), 0o600))
+		require.NoError(t, os.WriteFile(commands, []byte("<!-- play: deploy.cast -->\n\n<!-- play: deploy.cast -->\n<!-- speed: 2 -->\n$ kubectl apply -f deploy.yaml\n\n<!-- play: missing.cast -->\n"), 0o600))
+
+		s, err := NewServer(slog.New(slog.NewTextHandler(os.Stdout, nil)), 0, commands)
+		require.NoError(t, err)
+
+		srv, ok := s.(*server)
+		require.True(t, ok)
+		require.Len(t, srv.slides, 3)
+
+		assert.Equal(t, types.SlideTypePlayback, srv.slides[0].SlideType)
+		assert.Equal(t, filepath.Join(dir, "deploy.cast"), srv.slides[0].Recording)
+		assert.Equal(t, "./deploy.sh", srv.slides[0].Content)
+		assert.Equal(t, "./deploy.sh", srv.slides[0].Title)
+
+		assert.Equal(t, "kubectl apply -f deploy.yaml", srv.slides[1].Content)
+		assert.Empty(t, srv.slides[1].ExecuteContent)
+		assert.Equal(t, types.Playback{Speed: 2}, srv.slides[1].Playback)
+
+		// the recording can be made after the presentation is written
+		assert.Equal(t, "missing.cast", srv.slides[2].Content)
+	})
+
+	t.Run("Fallback", func(t *testing.T) {
+		dir := t.TempDir()
+		commands := filepath.Join(dir, "commands.txt")
+		require.NoError(t, os.WriteFile(commands, []byte("---\nfallback:\n  record: true\n---\n$ ls\n"), 0o600))
+
+		s, err := NewServer(slog.New(slog.NewTextHandler(os.Stdout, nil)), 0, commands)
+		require.NoError(t, err)
+
+		srv, ok := s.(*server)
+		require.True(t, ok)
+		assert.Equal(t, types.FallbackSettings{Record: true, Dir: filepath.Join(dir, ".backend-demo")}, srv.settings.Fallback)
+
+		require.NoError(t, os.WriteFile(commands, []byte("---\nfallback:\n  replay: sometimes\n---\n$ ls\n"), 0o600))
+		_, err = NewServer(slog.New(slog.NewTextHandler(os.Stdout, nil)), 0, commands)
+		assert.ErrorContains(t, err, "fallback replay must be offer, auto or off, not 'sometimes'")
+	})
+
+	t.Run("Transcript", func(t *testing.T) {
+		dir := t.TempDir()
+		commands := filepath.Join(dir, "commands.txt")
+		require.NoError(t, os.WriteFile(commands, []byte("---\ntranscript:\n  file: audit.jsonl\n  output: true\n---\n$ ls\n"), 0o600))
+
+		s, err := NewServer(slog.New(slog.NewTextHandler(os.Stdout, nil)), 0, commands)
+		require.NoError(t, err)
+
+		srv, ok := s.(*server)
+		require.True(t, ok)
+		assert.Equal(t, types.TranscriptSettings{File: filepath.Join(dir, "audit.jsonl"), Output: true}, srv.settings.Transcript)
+
+		require.NoError(t, os.WriteFile(commands, []byte("---\ntranscript:\n  file: audit.log\n  format: xml\n---\n$ ls\n"), 0o600))
+		_, err = NewServer(slog.New(slog.NewTextHandler(os.Stdout, nil)), 0, commands)
+		assert.ErrorContains(t, err, "transcript format must be text or json, not 'xml'")
+	})
+
+	t.Run("Missing shell", func(t *testing.T) {
+		commands := filepath.Join(t.TempDir(), "commands.txt")
+		require.NoError(t, os.WriteFile(commands, []byte("---\nshell: not-a-shell\n---\n$ ls\n"), 0o600))
+
+		_, err := NewServer(slog.New(slog.NewTextHandler(os.Stdout, nil)), 0, commands)
+		assert.ErrorContains(t, err, "could not find shell or interpreter: not-a-shell (slide 1)")
+
+		// nothing is run so the shell isn't needed
+		_, err = NewServer(slog.New(slog.NewTextHandler(os.Stdout, nil)), 0, commands, WithExecutionDisabled())
+		assert.NoError(t, err)
+	})
+
+	t.Run("TLS certificate without key", func(t *testing.T) {
+		_, err := NewServer(slog.New(slog.NewTextHandler(os.Stdout, nil)), 0, filepath.Join("..", "testdata", "commands.txt"), WithTLSCertificate("cert.pem", ""))
+		assert.ErrorContains(t, err, "both a TLS certificate and key must be provided")
+	})
+
+	t.Run("Unknown front matter field", func(t *testing.T) {
+		commands := filepath.Join(t.TempDir(), "commands.txt")
+		require.NoError(t, os.WriteFile(commands, []byte("---\ntitel: typo\n---\n$ ls\n"), 0o600))
+
+		_, err := NewServer(slog.New(slog.NewTextHandler(os.Stdout, nil)), 0, commands)
+		assert.ErrorContains(t, err, "field titel not found")
+	})
+}
+
+func TestRunHook(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+	s, err := NewServer(logger, 0, filepath.Join("..", "testdata", "setup-commands.txt"))
+	require.NoError(t, err)
+
+	srv, ok := s.(*server)
+	require.True(t, ok)
+
+	err = srv.runHook(context.Background(), "setup", srv.settings.Setup)
+	require.NoError(t, err)
+	assert.Contains(t, logs.String(), `msg="command output" commands=setup output="starting demo database"`)
+	assert.Contains(t, logs.String(), `msg="command output" commands=setup output="database ready"`)
+
+	err = srv.runHook(context.Background(), "teardown", srv.settings.Teardown)
+	require.NoError(t, err)
+	assert.Contains(t, logs.String(), `msg="command output" commands=teardown output="stopping demo database"`)
+
+	err = srv.runHook(context.Background(), "setup", []string{"echo partial", "exit 3"})
+	assert.ErrorContains(t, err, "setup commands failed: exit status 3")
+
+	t.Run("Execution disabled", func(t *testing.T) {
+		logs.Reset()
+		srv.settings.ExecutionDisabled = true
+
+		err = srv.runHook(context.Background(), "setup", []string{"exit 3"})
+		require.NoError(t, err)
+		assert.Contains(t, logs.String(), `msg="skipping commands since execution is disabled" commands=setup`)
+	})
+}
+
+func TestStart_SetupFailure(t *testing.T) {
+	commands := filepath.Join(t.TempDir(), "commands.txt")
+	require.NoError(t, os.WriteFile(commands, []byte("---\nsetup: [\"false\"]\nteardown: [\"echo teardown\"]\n---\n$ ls\n"), 0o600))
+
+	var logs bytes.Buffer
+	s, err := NewServer(slog.New(slog.NewTextHandler(&logs, nil)), 0, commands)
+	require.NoError(t, err)
+
+	// startup is aborted before the server starts listening and teardown isn't run
+	err = s.Start(context.Background())
+	assert.ErrorContains(t, err, "setup commands failed")
+	assert.NotContains(t, logs.String(), "server is running")
+	assert.NotContains(t, logs.String(), "teardown")
+}
+
+func TestStart_GracefulShutdown(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	require.NoError(t, listener.Close())
+
+	var logs syncBuffer
+	s, err := NewServer(slog.New(slog.NewTextHandler(&logs, nil)), port, filepath.Join("..", "testdata", "setup-commands.txt"))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error)
+	go func() {
+		errs <- s.Start(ctx)
+	}()
+
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%v/presentation", port))
+		if err != nil {
+			return false
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	select {
+	case err := <-errs:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not shut down")
+	}
+
+	assert.Contains(t, logs.String(), "shutting down server")
+	assert.Contains(t, logs.String(), `output="stopping demo database"`)
+}
+
+func TestStart_UnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "demo.sock")
+
+	s, err := NewServer(slog.New(slog.NewTextHandler(os.Stdout, nil)), 0, filepath.Join("..", "testdata", "commands.txt"), WithSocket(socket))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error)
+	go func() {
+		errs <- s.Start(ctx)
+	}()
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+
+	require.Eventually(t, func() bool {
+		resp, err := client.Get("http://unix/presentation")
+		if err != nil {
+			return false
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond)
+
+	info, err := os.Stat(socket)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	cancel()
+	require.NoError(t, <-errs)
+
+	_, err = os.Stat(socket)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// syncBuffer is a bytes.Buffer that can be written to by the server while the test reads it
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestSplitFrontMatter(t *testing.T) {
+	testCases := []struct {
+		name, input, frontMatter, body string
+	}{
+		{
+			name:  "no front matter",
+			input: "# slide\n\n$ ls\n",
+			body:  "# slide\n\n$ ls\n",
+		},
+		{
+			name:        "front matter",
+			input:       "---\ntitle: demo\n\nport: 1\n---\n# slide\n",
+			frontMatter: "title: demo\n\nport: 1\n",
+			body:        "# slide\n",
+		},
+		{
+			name:  "unterminated front matter",
+			input: "---\n# slide\n",
+			body:  "---\n# slide\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			frontMatter, body := splitFrontMatter(tc.input)
+			assert.Equal(t, tc.frontMatter, frontMatter)
+			assert.Equal(t, tc.body, body)
+		})
+	}
+}
+
+func TestParseDirectives(t *testing.T) {
+	directives, rest := parseDirectives("<!-- title: Markup -->\n<!-- steps: on -->\n```html\n<!-- header -->\n<h1>Hello</h1>\n```")
+	assert.Equal(t, map[string]string{"title": "Markup", "steps": "on"}, directives)
+	assert.Equal(t, "```html\n<!-- header -->\n<h1>Hello</h1>\n```", rest)
+
+	// comments after the start of a slide are part of it
+	directives, rest = parseDirectives("# Notes\n<!-- draft -->\ntext")
+	assert.Empty(t, directives)
+	assert.Equal(t, "# Notes\n<!-- draft -->\ntext", rest)
+
+	s := &server{}
+	s.ParseSlide("```html\n<!-- header -->\n<h1>Hello</h1>\n```")
+	slide, err := s.GetSlide(0)
+	require.NoError(t, err)
+	assert.Equal(t, types.SlideTypeCodeblock, slide.SlideType)
+	assert.Contains(t, slide.Content, "header")
+}
+
+func TestSlideTitles(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		title   string
+	}{
+		{
+			name:    "explicit title",
+			content: "<!-- title: Getting started -->\n# Welcome",
+			title:   "Getting started",
+		},
+		{
+			name:    "first heading",
+			content: "some intro text\n## The **architecture**",
+			title:   "The architecture",
+		},
+		{
+			name:    "first line",
+			content: "this is a [presentation](http://google.com)\nwith two lines",
+			title:   "this is a presentation",
+		},
+		{
+			name:    "image alt text",
+			content: "![hyperion](hyperion.jpg)",
+			title:   "hyperion",
+		},
+		{
+			name:    "code block",
+			content: "```go\n\nfunc main() {}\n```",
+			title:   "func main() {}",
+		},
+		{
+			name:    "command slide",
+			content: "$ export FOO=bar\n$ echo $FOO",
+			title:   "echo $FOO",
+		},
+		{
+			name:    "explicit title on command slide",
+			content: "<!-- title: Say hello -->\n$ echo hello",
+			title:   "Say hello",
+		},
+		{
+			name:    "long title",
+			content: strings.Repeat("word ", 20),
+			title:   strings.Repeat("word ", 11) + "word…",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &server{}
+			s.ParseSlide(tc.content)
+			slide, err := s.GetSlide(0)
+			require.NoError(t, err)
+			assert.Equal(t, tc.title, slide.Title)
+			assert.NotContains(t, slide.Content, "<!--")
+		})
+	}
+}
+
+func TestSearchSlides(t *testing.T) {
+	s := &server{}
+	s.ParseSlides([]string{
+		"# Introduction\nwhat we will cover",
+		"<!-- title: Listing files -->\n$ ls -la /tmp",
+		"# Deploying\nto staging",
+	})
+
+	ids := func(slides []types.Slide) (ids []int) {
+		for _, slide := range slides {
+			ids = append(ids, slide.ID)
+		}
+		return
+	}
+
+	assert.Equal(t, []int{0, 1, 2}, ids(s.SearchSlides("", true)))
+	assert.Equal(t, []int{0}, ids(s.SearchSlides("INTRO", true)))
+	assert.Equal(t, []int{1}, ids(s.SearchSlides("ls /tmp", true)))
+	assert.Equal(t, []int{1}, ids(s.SearchSlides("listing", true)))
+	assert.Equal(t, []int{2}, ids(s.SearchSlides("staging", true)))
+	assert.Empty(t, s.SearchSlides("kubernetes", true))
+
+	// hidden lines are only searched for the presenter
+	s.ParseSlide("$ export STAGE=staging\n$ make deploy")
+	assert.Equal(t, []int{2, 3}, ids(s.SearchSlides("staging", true)))
+	assert.Equal(t, []int{2}, ids(s.SearchSlides("staging", false)))
 }
 
 func TestParseCommandSlide(t *testing.T) {
@@ -139,6 +668,12 @@ func TestParseCommandSlide(t *testing.T) {
 			displayContent: []string{"line1", "line2", "line3"},
 			executeContent: []string{"line1", "line2", "line3"},
 		},
+		{
+			name:           "commands that run together",
+			input:          "$ export PORT=8080\n$[server] ./serve\n$[client] curl localhost:$PORT",
+			displayContent: []string{"[server] ./serve", "[client] curl localhost:$PORT"},
+			executeContent: []string{"export PORT=8080", "./serve", "curl localhost:$PORT"},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -211,3 +746,15 @@ func TestParsePlainSlide(t *testing.T) {
 		assert.Equal(t, expected, actual)
 	}
 }
+
+func TestParseSteps(t *testing.T) {
+	// each line shown on the slide is a step, which runs the hidden lines before it
+	assert.Equal(t, []types.Step{
+		{Hidden: []string{"export STAGE=dev", "cd api"}, Command: "make build"},
+		{Command: "make test"},
+		{Hidden: []string{"sleep 1"}, Command: "make deploy"},
+	}, parseSteps("$ export STAGE=dev\n$ cd api\n$! make build\n\n$! make test\n$ sleep 1\n$ make deploy"))
+
+	// hidden lines at the end still run
+	assert.Equal(t, []types.Step{{Command: "ls"}, {Hidden: []string{"rm -rf tmp"}}}, parseSteps("$! ls\n$ rm -rf tmp\nSome text"))
+}
@@ -0,0 +1,204 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/joshjennings98/backend-demo/server/v2/types"
+)
+
+const (
+	sessionVersion = 1
+
+	SessionEventSlide  = "slide"
+	SessionEventStart  = "start"
+	SessionEventStop   = "stop"
+	SessionEventOutput = "output"
+)
+
+// session is a recording of a whole presentation, the slides as they were presented and a timeline of what the
+// presenter did and what was shown in the terminal
+type session struct {
+	Version  int             `json:"version"`
+	Started  time.Time       `json:"started"`
+	Settings sessionSettings `json:"settings"`
+	Slides   []sessionSlide  `json:"slides"`
+	Events   []sessionEvent  `json:"events"`
+}
+
+// sessionSettings are the settings needed to show the slides as they were presented
+type sessionSettings struct {
+	Title    string                 `json:"title,omitempty"`
+	Theme    string                 `json:"theme,omitempty"`
+	Terminal types.TerminalSettings `json:"terminal"`
+	Assets   types.AssetSettings    `json:"assets"`
+}
+
+// sessionSlide is a slide as it was shown, without its commands, working directory or environment since they aren't
+// needed to replay it and may contain secrets
+type sessionSlide struct {
+	Title   string          `json:"title,omitempty"`
+	Content string          `json:"content"`
+	Type    types.SlideType `json:"type"`
+	Assets  []string        `json:"assets,omitempty"`
+}
+
+// sessionEvent is something that happened during the presentation, the time is in seconds from when it started
+type sessionEvent struct {
+	Time  float64 `json:"time"`
+	Type  string  `json:"type"`
+	Slide int     `json:"slide"`
+	Data  string  `json:"data,omitempty"`
+}
+
+// sessionRecorder records a presentation as it is given, the terminal output is written to it like a file
+type sessionRecorder struct {
+	mu      sync.Mutex
+	path    string
+	session session
+	pending []byte
+}
+
+func newSessionRecorder(path string, settings types.Settings, slides []types.Slide) *sessionRecorder {
+	r := &sessionRecorder{
+		path: path,
+		session: session{
+			Version: sessionVersion,
+			Started: time.Now(),
+			Settings: sessionSettings{
+				Title:    settings.Title,
+				Theme:    settings.Theme,
+				Terminal: settings.Terminal,
+				Assets:   settings.Assets,
+			},
+			Events: []sessionEvent{},
+		},
+	}
+
+	for _, slide := range slides {
+		r.session.Slides = append(r.session.Slides, sessionSlide{Title: slide.Title, Content: slide.Content, Type: slide.SlideType, Assets: slide.Assets})
+	}
+
+	return r
+}
+
+func (r *sessionRecorder) event(event sessionEvent) {
+	event.Time = time.Since(r.session.Started).Seconds()
+	r.session.Events = append(r.session.Events, event)
+}
+
+// record adds something the presenter did with a slide to the timeline
+func (r *sessionRecorder) record(kind string, slide int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.event(sessionEvent{Type: kind, Slide: slide})
+}
+
+func (r *sessionRecorder) Write(p []byte) (n int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// a character split across writes is held back so it isn't mangled by being encoded in halves
+	r.pending = append(r.pending, p...)
+	if end := completeRunes(r.pending); end > 0 {
+		r.event(sessionEvent{Type: SessionEventOutput, Data: string(r.pending[:end])})
+		r.pending = r.pending[end:]
+	}
+
+	n = len(p)
+	return
+}
+
+// save writes the session so far to its file, replacing it in one go so a copy being replayed is never half written
+func (r *sessionRecorder) save() (err error) {
+	r.mu.Lock()
+	data, err := json.Marshal(r.session)
+	r.mu.Unlock()
+	if err != nil {
+		return
+	}
+
+	if err = os.MkdirAll(filepath.Dir(r.path), 0o750); err != nil {
+		err = fmt.Errorf("could not create directory for session: %w", err)
+		return
+	}
+
+	tmp := r.path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o600); err != nil {
+		err = fmt.Errorf("could not write session: %w", err)
+		return
+	}
+
+	if err = os.Rename(tmp, r.path); err != nil {
+		_ = os.Remove(tmp)
+		err = fmt.Errorf("could not write session: %w", err)
+	}
+
+	return
+}
+
+// loadSession reads a recorded session
+func loadSession(path string) (recorded session, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		err = fmt.Errorf("could not read session: %w", err)
+		return
+	}
+
+	if err = json.Unmarshal(data, &recorded); err != nil {
+		err = fmt.Errorf("could not parse session %v: %w", path, err)
+		return
+	}
+
+	if recorded.Version != sessionVersion {
+		err = fmt.Errorf("session %v is version %v, only version %v is supported", path, recorded.Version, sessionVersion)
+		return
+	}
+
+	if len(recorded.Slides) == 0 {
+		err = fmt.Errorf("session %v doesn't have any slides", path)
+	}
+
+	return
+}
+
+// recordSession adds something the presenter did with a slide to the session recording if there is one, saving it
+// when the slide changes so that not much is lost if the server doesn't shut down cleanly
+func (s *server) recordSession(kind string, slide int) {
+	if s.session == nil {
+		return
+	}
+
+	s.session.record(kind, slide)
+	if kind == SessionEventSlide {
+		s.saveSession()
+	}
+}
+
+func (s *server) saveSession() {
+	if s.session == nil {
+		return
+	}
+
+	if err := s.session.save(); err != nil {
+		s.logger.Warn("could not save session", "file", s.session.path, "error", err)
+	}
+}
+
+func (s *server) HandlerSession(w http.ResponseWriter, r *http.Request) {
+	if s.replay == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.replay.Events); err != nil {
+		s.logger.Error("could not write session", "error", err.Error())
+	}
+}
@@ -0,0 +1,233 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/joshjennings98/backend-demo/server/v2/types"
+)
+
+func TestSessionRecorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "talk", "session.json")
+	settings := types.Settings{Title: "Demo", Theme: "light", Terminal: types.TerminalSettings{FontSize: 18}, Env: map[string]string{"TOKEN": "secret"}}
+	slides := []types.Slide{
+		{ID: 0, Title: "Intro", Content: "<h1>Intro</h1>", SlideType: types.SlideTypePlain, Assets: []string{"logo.png"}},
+		{ID: 1, Content: "echo hello", ExecuteContent: []string{"export TOKEN=secret", "echo hello"}, SlideType: types.SlideTypeCommand, WorkingDir: "/srv"},
+	}
+
+	recorder := newSessionRecorder(path, settings, slides)
+	recorder.record(SessionEventSlide, 1)
+	recorder.record(SessionEventStart, 1)
+
+	// a character split across writes
+	_, err := recorder.Write([]byte("caf\xc3"))
+	require.NoError(t, err)
+	_, err = recorder.Write([]byte("\xa9\n\r"))
+	require.NoError(t, err)
+	require.NoError(t, recorder.save())
+
+	recorded, err := loadSession(path)
+	require.NoError(t, err)
+	assert.InDelta(t, time.Now().Unix(), recorded.Started.Unix(), 5)
+	assert.Equal(t, sessionSettings{Title: "Demo", Theme: "light", Terminal: types.TerminalSettings{FontSize: 18}}, recorded.Settings)
+
+	// only what is needed to show the slides is kept
+	assert.Equal(t, []sessionSlide{
+		{Title: "Intro", Content: "<h1>Intro</h1>", Type: types.SlideTypePlain, Assets: []string{"logo.png"}},
+		{Content: "echo hello", Type: types.SlideTypeCommand},
+	}, recorded.Slides)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "secret")
+
+	require.Len(t, recorded.Events, 4)
+	assert.Equal(t, sessionEvent{Time: recorded.Events[0].Time, Type: SessionEventSlide, Slide: 1}, recorded.Events[0])
+	assert.Equal(t, SessionEventStart, recorded.Events[1].Type)
+	assert.Equal(t, sessionEvent{Time: recorded.Events[2].Time, Type: SessionEventOutput, Data: "caf"}, recorded.Events[2])
+	assert.Equal(t, "é\n\r", recorded.Events[3].Data)
+	assert.LessOrEqual(t, recorded.Events[0].Time, recorded.Events[3].Time)
+
+	// the first slide is written out rather than left for whatever reads the session to assume
+	data, err = json.Marshal(sessionEvent{Type: SessionEventSlide})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"time": 0, "type": "slide", "slide": 0}`, string(data))
+}
+
+func TestLoadSession(t *testing.T) {
+	dir := t.TempDir()
+	write := func(contents string) string {
+		path := filepath.Join(dir, "session.json")
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0o600))
+		return path
+	}
+
+	_, err := loadSession(filepath.Join(dir, "missing.json"))
+	assert.ErrorContains(t, err, "could not read session")
+
+	_, err = loadSession(write("not json"))
+	assert.ErrorContains(t, err, "could not parse session")
+
+	_, err = loadSession(write(`{"version": 2, "slides": [{"content": "hello"}]}`))
+	assert.ErrorContains(t, err, "only version 1 is supported")
+
+	_, err = loadSession(write(`{"version": 1, "slides": []}`))
+	assert.ErrorContains(t, err, "doesn't have any slides")
+}
+
+func TestCommandManager_TerminalRecorder(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	cm := newCommandManager(logger, types.Settings{}, nil)
+
+	recorder := newSessionRecorder(filepath.Join(t.TempDir(), "session.json"), types.Settings{}, nil)
+	cm.SetTerminalRecorder(recorder)
+
+	ws, cleanup := setupWebSocket(t, cm)
+	defer cleanup()
+
+	require.NoError(t, cm.Run(types.Slide{ExecuteContent: []string{"echo hello"}}, ""))
+	readTerminal(t, ws, "hello")
+
+	// the session is written after the message is sent
+	output := func() (output string) {
+		recorder.mu.Lock()
+		defer recorder.mu.Unlock()
+
+		for _, event := range recorder.session.Events {
+			assert.Equal(t, SessionEventOutput, event.Type)
+			output += event.Data
+		}
+		return
+	}
+	assert.Eventually(t, func() bool { return output() == "\033[2J\033[Hhello\n\r" }, time.Second, 10*time.Millisecond, "output: %q", output())
+}
+
+func TestSessionRecording(t *testing.T) {
+	s, cmdManager := setupServer(t)
+	s.session = newSessionRecorder(filepath.Join(t.TempDir(), "session.json"), s.settings, s.slides)
+
+	cmdManager.EXPECT().Stop().Return(nil).Times(2)
+	cmdManager.EXPECT().Clear().Return(nil)
+	cmdManager.EXPECT().Run(s.slides[1], "192.0.2.1:1234").Return(nil)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /slides/{id}", s.HandlerSlideByIndex)
+	mux.HandleFunc("POST /commands/{id}/start", s.HandlerCommandStart)
+	mux.HandleFunc("POST /commands/{id}/stop", s.HandlerCommandStop)
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/slides/1", nil),
+		httptest.NewRequest("POST", "/commands/1/start", nil),
+		httptest.NewRequest("POST", "/commands/1/stop", nil),
+	} {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+	}
+
+	// the session is saved when the slide changes
+	recorded, err := loadSession(s.session.path)
+	require.NoError(t, err)
+	require.Len(t, recorded.Events, 1)
+	assert.Equal(t, sessionEvent{Time: recorded.Events[0].Time, Type: SessionEventSlide, Slide: 1}, recorded.Events[0])
+
+	s.saveSession()
+	recorded, err = loadSession(s.session.path)
+	require.NoError(t, err)
+
+	var kinds []string
+	for _, event := range recorded.Events {
+		kinds = append(kinds, event.Type)
+	}
+	assert.Equal(t, []string{SessionEventSlide, SessionEventStart, SessionEventStop}, kinds)
+}
+
+func TestNewReplayServer(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "session.json")
+	recorder := newSessionRecorder(path, types.Settings{Title: "Demo"}, []types.Slide{
+		{ID: 0, Content: "<h1>Intro</h1>", SlideType: types.SlideTypePlain},
+		{ID: 1, Content: "echo hello", SlideType: types.SlideTypeCommand},
+	})
+	recorder.record(SessionEventSlide, 1)
+	_, err := recorder.Write([]byte("hello\n\r"))
+	require.NoError(t, err)
+	require.NoError(t, recorder.save())
+
+	s, err := NewReplayServer(slog.New(slog.NewTextHandler(os.Stdout, nil)), 0, path, WithListenAddress("0.0.0.0"))
+	require.NoError(t, err)
+
+	srv, ok := s.(*server)
+	require.True(t, ok)
+	assert.Equal(t, "Demo", srv.settings.Title)
+	assert.Equal(t, "0.0.0.0", srv.settings.Listen.Address)
+	assert.Equal(t, defaultPort, srv.settings.Port)
+	assert.True(t, srv.settings.ExecutionDisabled)
+	require.Len(t, srv.slides, 2)
+	assert.Equal(t, types.Slide{ID: 1, Content: "echo hello", SlideType: types.SlideTypeCommand}, srv.slides[1])
+
+	t.Run("Index", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		srv.HandlerIndex(rr, httptest.NewRequest("GET", "/presentation", nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), `data-replay="true"`)
+	})
+
+	t.Run("Session", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		srv.HandlerSession(rr, httptest.NewRequest("GET", "/session", nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var events []sessionEvent
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&events))
+		require.Len(t, events, 2)
+		assert.Equal(t, "hello\n\r", events[1].Data)
+	})
+
+	t.Run("Not replaying", func(t *testing.T) {
+		live, _ := setupServer(t)
+		rr := httptest.NewRecorder()
+		live.HandlerSession(rr, httptest.NewRequest("GET", "/session", nil))
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	// the session file isn't served as an asset since it has the output of every command
+	assert.False(t, srv.isServableAsset("session.json"))
+
+	_, err = NewReplayServer(slog.New(slog.NewTextHandler(os.Stdout, nil)), 0, filepath.Join(dir, "missing.json"))
+	assert.ErrorContains(t, err, "could not read session")
+}
+
+func TestSessionRecording_ExecutionDisabled(t *testing.T) {
+	for name, opt := range map[string]Option{"execution disabled": WithExecutionDisabled(), "safe mode": WithSafeMode(nil)} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			victim := filepath.Join(dir, "victim.txt")
+			require.NoError(t, os.WriteFile(victim, []byte("precious"), 0o600))
+			commands := filepath.Join(dir, "commands.txt")
+			require.NoError(t, os.WriteFile(commands, []byte("---\nrecord:\n  session: victim.txt\n---\n# Intro\n\n$ echo hello\n"), 0o600))
+
+			s, err := newServer(slog.New(slog.NewTextHandler(os.Stdout, nil)), 0, commands, opt)
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /slides/{id}", s.HandlerSlideByIndex)
+			mux.ServeHTTP(rr, httptest.NewRequest("GET", "/slides/1", nil))
+			require.Equal(t, http.StatusOK, rr.Code)
+			s.saveSession()
+
+			data, err := os.ReadFile(victim)
+			require.NoError(t, err)
+			assert.Equal(t, "precious", string(data))
+		})
+	}
+}
@@ -6,11 +6,21 @@ window.addEventListener('load', function () {
     const WS_MAX_RETRY_DELAY_MS = 30000;
     const WS_BACKOFF_MULTIPLIER = 2;
 
+    // terminal settings from the front matter of the presentation are passed as data attributes on the body
+    var settings = document.body.dataset;
+    var isLightTheme = document.body.classList.contains('theme-light');
+
     var term = new Terminal({
-        fontSize: TERMINAL_FONT_SIZE,
-        fontFamily: TERMINAL_FONT_FAMILY,
-        scrollback: TERMINAL_SCROLLBACK,
-        theme: {
+        fontSize: Number(settings.terminalFontSize) || TERMINAL_FONT_SIZE,
+        fontFamily: settings.terminalFontFamily || TERMINAL_FONT_FAMILY,
+        scrollback: Number(settings.terminalScrollback) || TERMINAL_SCROLLBACK,
+        theme: isLightTheme ? {
+            background: 'transparent',
+            foreground: '#1a1a1a',
+            cursor: '#1a1a1a',
+            cursorAccent: '#fff',
+            selectionBackground: 'rgba(0, 0, 0, 0.2)',
+        } : {
             background: 'transparent',
             foreground: '#00ff00',
             cursor: '#00ff00',
@@ -23,13 +33,22 @@ window.addEventListener('load', function () {
     var socket;
     var retryDelay = WS_INITIAL_RETRY_DELAY_MS;
 
+    // when the presentation requires a presenter session the websocket is authenticated with the page's CSRF token,
+    // which is offered as a subprotocol so it isn't in the URL
+    var csrfToken = document.querySelector('meta[name="csrf-token"]');
+
     function initWebSocket() {
-        var socketUrl = 'ws://' + window.location.host + '/ws';
+        var scheme = window.location.protocol === 'https:' ? 'wss://' : 'ws://';
+        var socketUrl = scheme + window.location.host + '/ws';
+        var protocols = ['backend-demo'];
+        if (csrfToken) {
+            protocols.push(csrfToken.content);
+        }
 
         if (socket) {
             socket.close();
         }
-        socket = new WebSocket(socketUrl);
+        socket = new WebSocket(socketUrl, protocols);
 
         socket.onopen = function (e) {
             console.log(`Connection established to ${socketUrl}`);
@@ -55,7 +74,130 @@ window.addEventListener('load', function () {
         };
     }
 
-    initWebSocket();
+    // a recorded session is played back from its timeline, changing slides and writing the terminal output at the
+    // times they happened, and can be paused with p
+    function replaySession() {
+        var events = [];
+        var next = 0;
+        var elapsed = 0;
+        var resumed = null;
+        var timer = null;
+
+        function apply(event) {
+            if (event.type === 'slide') {
+                htmx.ajax('GET', '/slides/' + event.slide, {target: '#command', swap: 'outerHTML'});
+            } else if (event.type === 'output') {
+                term.write(event.data);
+            }
+        }
+
+        function play() {
+            elapsed += performance.now() - resumed;
+            resumed = performance.now();
+
+            while (next < events.length && events[next].time * 1000 <= elapsed) {
+                apply(events[next++]);
+            }
+
+            if (next < events.length) {
+                timer = setTimeout(play, events[next].time * 1000 - elapsed);
+            }
+        }
+
+        document.body.addEventListener('keyup', function (event) {
+            if (event.key !== 'p') return;
+
+            if (resumed === null) {
+                resumed = performance.now();
+                play();
+            } else {
+                clearTimeout(timer);
+                elapsed += performance.now() - resumed;
+                resumed = null;
+            }
+        });
+
+        fetch('/session')
+            .then(function (response) { return response.json(); })
+            .then(function (timeline) {
+                events = timeline;
+                resumed = performance.now();
+                play();
+            })
+            .catch(function (error) {
+                console.error(`Could not load session: ${error.message}`);
+            });
+    }
+
+    // an exported presentation has every slide in a template, and the recorded output of its commands, so slides are
+    // shown and output is played without the server
+    function offlinePresentation() {
+        var recordings = JSON.parse(document.getElementById('recordings').textContent);
+        var timers = [];
+
+        function stop() {
+            timers.forEach(clearTimeout);
+            timers = [];
+            term.reset();
+        }
+
+        function play(id) {
+            stop();
+            (recordings[id] || []).forEach(function (event) {
+                timers.push(setTimeout(function () { term.write(event.data); }, event.time * 1000));
+            });
+        }
+
+        function showSlide(id) {
+            var template = document.getElementById('slide-' + id);
+            var current = document.getElementById('command');
+            if (!template || !current) return;
+
+            stop();
+            var previous = getSlideType();
+            var next = template.content.firstElementChild.cloneNode(true);
+            // the terminal is kept, like htmx does with hx-preserve
+            next.querySelector('#terminal').replaceWith(document.getElementById('terminal'));
+            current.replaceWith(next);
+            htmx.process(next);
+
+            if (previous !== getSlideType()) {
+                document.getElementById('slide-content').classList.add('fade-in');
+            }
+        }
+
+        // the controls ask for slides by their URL, or by the value of the dropdown
+        document.body.addEventListener('htmx:confirm', function (event) {
+            event.preventDefault();
+
+            var match = event.detail.path.match(/^\/slides\/(\d+)$/);
+            if (match) {
+                showSlide(match[1]);
+            } else if (event.detail.path === '/slides') {
+                showSlide(event.detail.elt.value);
+            }
+        });
+
+        document.body.addEventListener('click', function (event) {
+            var button = event.target.closest('[data-recording]');
+            if (button) play(button.dataset.recording);
+        });
+
+        document.body.addEventListener('keyup', function (event) {
+            var button = document.querySelector('[data-recording]');
+            // space on the focused button clicks it already
+            if (event.key === ' ' && button && event.target !== button) play(button.dataset.recording);
+        });
+    }
+
+    // viewers without a presenter session can't connect to the terminal
+    if (settings.offline) {
+        offlinePresentation();
+    } else if (settings.replay) {
+        replaySession();
+    } else if (!settings.readOnly) {
+        initWebSocket();
+    }
 
     function setupHighlighting() {
         hljs.highlightAll();
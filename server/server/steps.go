@@ -0,0 +1,341 @@
+package server
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joshjennings98/backend-demo/server/v2/types"
+)
+
+// stepScript runs a line in the shell of a step-through slide and then writes its exit status to file descriptor 3.
+// The line can't read stdin, so it doesn't swallow the lines after it, and doesn't inherit the status descriptor, so
+// anything it leaves running in the background doesn't hold it open.
+const stepScript = "{\n%v\n} </dev/null 3>&-\necho $? >&3\n"
+
+// stepSession is the shell that the lines of a step-through slide run in one at a time so they share its state
+type stepSession struct {
+	slide  types.Slide
+	limits types.Limits
+	// next is the index of the line that runs on the next step
+	next     int
+	ctx      context.Context
+	stop     context.CancelCauseFunc
+	cmd      *exec.Cmd
+	stdin    io.WriteCloser
+	statuses chan string
+	terminal *wsWriter
+	redacted *redactWriter
+	output   *outputTail
+	// capture keeps the output of each step for the transcript, it is nil if it isn't needed
+	capture *captureWriter
+	// pending is the last step that ran, it is added to the transcript once all its output has arrived
+	pending   *transcriptEntry
+	pendingMu sync.Mutex
+	exited    chan struct{}
+	// err is how the shell exited, it is set before exited is closed
+	err error
+}
+
+func (c *commandManager) NextStep(id int) int {
+	c.stepsMu.Lock()
+	defer c.stepsMu.Unlock()
+
+	if c.steps != nil && c.steps.slide.ID == id {
+		return c.steps.next
+	}
+
+	return 0
+}
+
+// step runs the next line of a step-through slide, starting a shell for the slide when its first line runs
+func (c *commandManager) step(slide types.Slide, client string) (err error) {
+	// the stop button is shown while a step is running so the step has already been started
+	if c.IsRunning() {
+		return
+	}
+
+	c.stepsMu.Lock()
+	session := c.steps
+	c.stepsMu.Unlock()
+
+	if session == nil || session.slide.ID != slide.ID {
+		_ = c.Stop()
+
+		if !c.IsWebsocketConnected() {
+			return
+		}
+
+		if err := c.Clear(); err != nil {
+			c.logger.Warn("failed to clear terminal", "error", err)
+		}
+
+		var ctx context.Context
+		ctx, c.cancel = context.WithCancel(context.Background())
+
+		if session, err = c.startSteps(ctx, slide, c.settings.Limits.Override(slide.Limits)); err != nil {
+			_ = c.Stop()
+			return
+		}
+	}
+
+	c.stepsMu.Lock()
+	c.steps = session
+	idx := session.next
+	session.next++
+	c.stepsMu.Unlock()
+
+	c.running.Store(true)
+	c.wg.Add(1)
+	go c.runStep(session, idx, client)
+
+	return
+}
+
+// startSteps starts the shell for a step-through slide, the limits apply to the shell as a whole apart from the
+// timeout which applies to each step
+func (c *commandManager) startSteps(ctx context.Context, slide types.Slide, limits types.Limits) (session *stepSession, err error) {
+	c.logger.Info("starting step-through commands", "steps", len(slide.StepCommands))
+
+	session = &stepSession{
+		slide:    slide,
+		limits:   limits,
+		statuses: make(chan string, len(slide.StepCommands)),
+		terminal: c.terminal(ctx),
+		output:   c.resetOutput(slide.ID),
+		capture:  c.capture(),
+		exited:   make(chan struct{}),
+	}
+	session.ctx, session.stop = context.WithCancelCause(ctx)
+
+	statusReader, statusWriter, err := os.Pipe()
+	if err != nil {
+		err = fmt.Errorf("could not create pipe for step statuses: %w", err)
+		return
+	}
+	// the shell has its own copy once it has started
+	defer func() { _ = statusWriter.Close() }()
+
+	session.cmd = c.process(session.ctx, slide, withLimits(strings.Fields(c.interpreter(slide)), limits))
+	session.cmd.ExtraFiles = []*os.File{statusWriter}
+	if session.stdin, err = session.cmd.StdinPipe(); err != nil {
+		_ = statusReader.Close()
+		err = fmt.Errorf("could not open stdin of shell: %w", err)
+		return
+	}
+
+	outputs := []io.Writer{session.output, session.terminal}
+	if session.capture != nil {
+		outputs = append(outputs, session.capture)
+	}
+
+	session.redacted = c.redactor.Writer(io.MultiWriter(outputs...))
+	writer := newLimitWriter(session.redacted, limits.MaxOutput, func() {
+		session.stop(&limitError{limit: "output", value: limits.MaxOutput.String()})
+	})
+	session.cmd.Stdout = writer
+	session.cmd.Stderr = writer
+
+	recording := c.record(slide)
+	if recording != nil {
+		session.terminal.recorder = recording
+	}
+
+	if err = session.cmd.Start(); err != nil {
+		_ = statusReader.Close()
+		if recording != nil {
+			_ = recording.Close()
+		}
+		err = fmt.Errorf("could not start shell: %w", err)
+		return
+	}
+
+	go func() {
+		defer close(session.statuses)
+		defer func() { _ = statusReader.Close() }()
+
+		scanner := bufio.NewScanner(statusReader)
+		for scanner.Scan() {
+			session.statuses <- scanner.Text()
+		}
+	}()
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+
+		session.err = session.cmd.Wait()
+		_ = session.redacted.Close()
+		c.flushStepTranscript(session)
+		if session.ctx.Err() != nil {
+			// clean up anything the steps left running in the background
+			killProcessGroup(session.cmd)
+		} else if session.err != nil {
+			c.logger.Error("step-through shell failed", "error", session.err)
+		}
+
+		c.reportLimit(session.ctx, session.err, session.limits, session.output, session.terminal)
+		if recording != nil {
+			_ = recording.Close()
+		}
+		close(session.exited)
+	}()
+
+	return
+}
+
+// runStep shows a line of a step-through slide in the terminal and runs it in the slide's shell, along with the
+// hidden lines before it which aren't shown
+func (c *commandManager) runStep(session *stepSession, idx int, client string) {
+	defer func() {
+		c.running.Store(false)
+		c.wg.Done()
+	}()
+
+	steps := len(session.slide.StepCommands)
+	step := session.slide.StepCommands[idx]
+	c.logger.Info("executing step", "step", idx+1, "steps", steps, "command", step.Command)
+
+	// finish any partial line of output from the previous step before showing the next one
+	_ = session.redacted.Flush()
+	c.flushStepTranscript(session)
+
+	var err error
+	typewriter := c.typewriter(session.slide)
+	switch shown := c.redactor.Redact(step.Command); {
+	case shown == "":
+		// nothing is shown for hidden lines that aren't followed by a line that is
+	case typewriter.Enabled:
+		err = typeCommand(session.ctx, session.terminal, shown, typewriter)
+	default:
+		_, err = fmt.Fprintf(session.terminal, "%v%v\n", cmp.Or(typewriter.Prompt, defaultPrompt), shown)
+	}
+
+	start := time.Now()
+	if err == nil {
+		_, err = fmt.Fprintf(session.stdin, stepScript, strings.Join(stepLines(step), "\n"))
+	}
+	if err != nil {
+		c.logger.Info("steps stopped", "error", err)
+		session.stop(err)
+		c.finishSteps(session)
+		return
+	}
+
+	var timeout <-chan time.Time
+	if session.limits.Timeout > 0 {
+		timer := time.NewTimer(session.limits.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	code := -1
+	var reason error
+	select {
+	case status, ok := <-session.statuses:
+		if !ok {
+			// the shell exited, e.g. from a syntax error or an exit command
+			break
+		}
+
+		c.logger.Info("step completed", "step", idx+1, "steps", steps, "status", status)
+		code, _ = strconv.Atoi(status)
+		if idx < steps-1 {
+			c.transcribeStep(session, idx, client, start, code, nil)
+			return
+		}
+
+		// the shell exits once the last step has run
+		_ = session.stdin.Close()
+	case <-timeout:
+		reason = &limitError{limit: "timeout", value: session.limits.Timeout.String()}
+		session.stop(reason)
+	case <-session.ctx.Done():
+	}
+
+	c.finishSteps(session)
+
+	if reason == nil && session.ctx.Err() != nil {
+		if reason = context.Cause(session.ctx); errors.Is(reason, context.Canceled) {
+			reason = errStopped
+		}
+	} else if code == -1 && reason == nil {
+		code = exitCode(session.err)
+	}
+	c.transcribeStep(session, idx, client, start, code, reason)
+	c.flushStepTranscript(session)
+}
+
+// transcribeStep adds a line of a step-through slide that ran to the transcript. Its output can still be arriving
+// after its status so it is added when the next step starts or the shell exits.
+func (c *commandManager) transcribeStep(session *stepSession, idx int, client string, start time.Time, code int, reason error) {
+	if c.settings.Transcript.File == "" {
+		return
+	}
+
+	entry := transcriptEntry{
+		Slide:    session.slide.ID,
+		Title:    session.slide.Title,
+		Commands: stepLines(session.slide.StepCommands[idx]),
+		Client:   client,
+		Start:    start,
+		End:      time.Now(),
+		ExitCode: code,
+	}
+	if reason != nil {
+		entry.Error = reason.Error()
+	}
+
+	session.pendingMu.Lock()
+	session.pending = &entry
+	session.pendingMu.Unlock()
+}
+
+// stepLines are the lines a step of a step-through slide runs
+func stepLines(step types.Step) []string {
+	if step.Command == "" {
+		return step.Hidden
+	}
+
+	return append(slices.Clone(step.Hidden), step.Command)
+}
+
+// flushStepTranscript adds the last step that ran in a step-through slide's shell to the transcript with its output
+func (c *commandManager) flushStepTranscript(session *stepSession) {
+	session.pendingMu.Lock()
+	defer session.pendingMu.Unlock()
+
+	if session.pending == nil {
+		return
+	}
+
+	entry := *session.pending
+	session.pending = nil
+	if session.capture != nil {
+		_ = session.redacted.Flush()
+		entry.Output = session.capture.take()
+	}
+
+	c.transcribe(entry)
+}
+
+// finishSteps waits for the shell of a step-through slide to exit so that the next step starts from the first line
+func (c *commandManager) finishSteps(session *stepSession) {
+	<-session.exited
+
+	c.stepsMu.Lock()
+	if c.steps == session {
+		c.steps = nil
+	}
+	c.stepsMu.Unlock()
+}
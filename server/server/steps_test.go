@@ -0,0 +1,106 @@
+package server
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/joshjennings98/backend-demo/server/v2/types"
+)
+
+// readTerminal reads messages from the terminal websocket until the output contains until
+func readTerminal(t *testing.T, ws *websocket.Conn, until string) (terminal string) {
+	t.Helper()
+
+	require.NoError(t, ws.SetReadDeadline(time.Now().Add(5*time.Second)))
+	for !strings.Contains(terminal, until) {
+		_, msg, err := ws.ReadMessage()
+		require.NoError(t, err, "terminal so far: %q", terminal)
+		terminal += string(msg)
+	}
+
+	return
+}
+
+func TestCommandManager_Steps(t *testing.T) {
+	dir := t.TempDir()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	cm := newCommandManager(logger, types.Settings{}, nil)
+
+	ws, cleanup := setupWebSocket(t, cm)
+	defer cleanup()
+
+	slide := types.Slide{ID: 2, Steps: true, StepCommands: []types.Step{
+		{Hidden: []string{"export GREETING=hello"}, Command: "cat"},
+		{Command: "cd " + dir},
+		{Command: `echo "$GREETING from $PWD"`},
+	}}
+
+	for i := range slide.StepCommands {
+		require.NoError(t, cm.Run(slide, ""))
+		assert.Eventually(t, func() bool { return !cm.IsRunning() }, 5*time.Second, 10*time.Millisecond)
+
+		if i < len(slide.StepCommands)-1 {
+			assert.Equal(t, i+1, cm.NextStep(slide.ID))
+			assert.Zero(t, cm.NextStep(slide.ID+1))
+		}
+	}
+
+	// the lines share the shell's state and are shown as they run, apart from the hidden ones
+	terminal := readTerminal(t, ws, "hello from "+dir)
+	assert.NotContains(t, terminal, "export GREETING")
+	assert.Contains(t, terminal, "$ cat\n\r$ cd "+dir)
+
+	// the next run starts from the first line again
+	assert.Zero(t, cm.NextStep(slide.ID))
+	assert.Contains(t, cm.LastOutput(slide.ID), "hello from "+dir)
+
+	t.Run("Stop", func(t *testing.T) {
+		require.NoError(t, cm.Run(slide, ""))
+		assert.Eventually(t, func() bool { return !cm.IsRunning() }, 5*time.Second, 10*time.Millisecond)
+		assert.Equal(t, 1, cm.NextStep(slide.ID))
+
+		require.NoError(t, cm.Stop())
+		assert.Zero(t, cm.NextStep(slide.ID))
+	})
+
+	t.Run("Shell exits", func(t *testing.T) {
+		exiting := types.Slide{ID: 3, Steps: true, StepCommands: []types.Step{{Command: "exit 3"}, {Command: "echo unreachable"}}}
+
+		require.NoError(t, cm.Run(exiting, ""))
+		assert.Eventually(t, func() bool { return !cm.IsRunning() }, 5*time.Second, 10*time.Millisecond)
+		assert.Zero(t, cm.NextStep(exiting.ID))
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		slow := types.Slide{ID: 4, Steps: true, Limits: types.Limits{Timeout: 100 * time.Millisecond}, StepCommands: []types.Step{{Command: "echo fast"}, {Command: "sleep 5"}}}
+
+		require.NoError(t, cm.Run(slow, ""))
+		assert.Eventually(t, func() bool { return !cm.IsRunning() }, 5*time.Second, 10*time.Millisecond)
+		assert.Empty(t, cm.Result(slow.ID).LimitHit)
+
+		require.NoError(t, cm.Run(slow, ""))
+		assert.Eventually(t, func() bool { return !cm.IsRunning() }, 5*time.Second, 10*time.Millisecond)
+		assert.Equal(t, "timeout limit of 100ms exceeded", cm.Result(slow.ID).LimitHit)
+		assert.Zero(t, cm.NextStep(slow.ID))
+	})
+}
+
+func TestCommandManager_CheckInterpreters_Steps(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	cm := newCommandManager(logger, types.Settings{}, nil)
+
+	assert.NoError(t, cm.CheckInterpreters([]types.Slide{{SlideType: types.SlideTypeCommand, Steps: true, Shell: "bash"}}))
+	assert.NoError(t, cm.CheckInterpreters([]types.Slide{{SlideType: types.SlideTypeCommand, Steps: true, Shell: "/bin/sh -e"}}))
+	assert.ErrorContains(t, cm.CheckInterpreters([]types.Slide{{SlideType: types.SlideTypeCommand, Steps: true, Shell: "python3"}}), "step-through slides need a POSIX shell such as sh or bash: python3 (slide 1)")
+
+	// fish is a shell but its syntax isn't POSIX
+	assert.ErrorContains(t, cm.CheckInterpreters([]types.Slide{{SlideType: types.SlideTypeCommand, Steps: true, Shell: "fish"}}), "step-through slides need a POSIX shell such as sh or bash: fish (slide 1)")
+}
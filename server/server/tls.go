@@ -0,0 +1,163 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	certificateValidity = 365 * 24 * time.Hour
+	certificateRenewal  = 7 * 24 * time.Hour
+	certificateFile     = "cert.pem"
+	keyFile             = "key.pem"
+)
+
+var defaultCertificateHosts = []string{"localhost", "127.0.0.1", "::1"}
+
+// certificateHosts returns the hosts a self-signed certificate is generated for, the defaults are always included
+func certificateHosts(hosts []string, address string) []string {
+	hosts = append(slices.Clone(defaultCertificateHosts), hosts...)
+	if ip := net.ParseIP(address); address != "" && (ip == nil || !ip.IsUnspecified()) {
+		hosts = append(hosts, address)
+	}
+
+	slices.Sort(hosts)
+	return slices.Compact(hosts)
+}
+
+// selfSignedCertificate loads the self-signed certificate for the hosts from the cache directory,
+// generating a new one if there isn't one or it is about to expire.
+func selfSignedCertificate(cacheDir string, hosts []string) (cert tls.Certificate, err error) {
+	hash := sha256.Sum256([]byte(strings.Join(hosts, ",")))
+	dir := filepath.Join(cacheDir, hex.EncodeToString(hash[:8]))
+	certPath := filepath.Join(dir, certificateFile)
+	keyPath := filepath.Join(dir, keyFile)
+
+	cert, err = tls.LoadX509KeyPair(certPath, keyPath)
+	if err == nil && time.Until(cert.Leaf.NotAfter) > certificateRenewal {
+		return
+	}
+
+	certPEM, keyPEM, err := generateCertificate(hosts)
+	if err != nil {
+		return
+	}
+
+	if err = os.MkdirAll(dir, 0o700); err != nil {
+		err = fmt.Errorf("could not create certificate cache '%v': %w", dir, err)
+		return
+	}
+
+	err = errors.Join(
+		os.WriteFile(certPath, certPEM, 0o600),
+		os.WriteFile(keyPath, keyPEM, 0o600),
+	)
+	if err != nil {
+		err = fmt.Errorf("could not cache certificate: %w", err)
+		return
+	}
+
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+func generateCertificate(hosts []string) (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		err = fmt.Errorf("could not generate private key: %w", err)
+		return
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		err = fmt.Errorf("could not generate serial number: %w", err)
+		return
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"backend-demo self-signed"}, CommonName: hosts[0]},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(certificateValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		err = fmt.Errorf("could not create certificate: %w", err)
+		return
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		err = fmt.Errorf("could not encode private key: %w", err)
+		return
+	}
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return
+}
+
+// tlsConfig loads the configured certificate or the cached self-signed one, it returns nil when TLS isn't enabled
+func (s *server) tlsConfig() (config *tls.Config, err error) {
+	settings := s.settings.TLS
+	if !settings.Enabled() {
+		return
+	}
+
+	var cert tls.Certificate
+	if settings.Cert != "" {
+		cert, err = tls.LoadX509KeyPair(settings.Cert, settings.Key)
+		if err != nil {
+			err = fmt.Errorf("could not load certificate '%v': %w", settings.Cert, err)
+			return
+		}
+	} else {
+		var cacheDir string
+		cacheDir, err = os.UserCacheDir()
+		if err != nil {
+			err = fmt.Errorf("could not find cache directory for self-signed certificate: %w", err)
+			return
+		}
+
+		hosts := certificateHosts(settings.Hosts, s.settings.Listen.Address)
+		cert, err = selfSignedCertificate(filepath.Join(cacheDir, "backend-demo", "tls"), hosts)
+		if err != nil {
+			return
+		}
+
+		s.logger.Info("using self-signed certificate, your browser will ask you to trust it", "hosts", strings.Join(hosts, ","))
+	}
+
+	config = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	return
+}
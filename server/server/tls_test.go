@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertificateHosts(t *testing.T) {
+	assert.Equal(t, []string{"127.0.0.1", "::1", "localhost"}, certificateHosts(nil, "localhost"))
+	assert.Equal(t, []string{"127.0.0.1", "::1", "localhost"}, certificateHosts(nil, "0.0.0.0"))
+	assert.Equal(t, []string{"127.0.0.1", "192.168.1.5", "::1", "demo.local", "localhost"}, certificateHosts([]string{"demo.local"}, "192.168.1.5"))
+}
+
+func TestSelfSignedCertificate(t *testing.T) {
+	cacheDir := t.TempDir()
+	hosts := []string{"127.0.0.1", "demo.local", "localhost"}
+
+	cert, err := selfSignedCertificate(cacheDir, hosts)
+	require.NoError(t, err)
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	assert.Equal(t, []string{"demo.local", "localhost"}, leaf.DNSNames)
+	require.Len(t, leaf.IPAddresses, 1)
+	assert.Equal(t, "127.0.0.1", leaf.IPAddresses[0].String())
+	assert.NoError(t, leaf.VerifyHostname("demo.local"))
+	assert.WithinDuration(t, time.Now().Add(certificateValidity), leaf.NotAfter, time.Minute)
+
+	t.Run("cached", func(t *testing.T) {
+		cached, err := selfSignedCertificate(cacheDir, hosts)
+		require.NoError(t, err)
+		assert.Equal(t, cert.Certificate, cached.Certificate)
+	})
+
+	t.Run("different hosts", func(t *testing.T) {
+		other, err := selfSignedCertificate(cacheDir, []string{"localhost"})
+		require.NoError(t, err)
+		assert.NotEqual(t, cert.Certificate, other.Certificate)
+	})
+}
+
+func TestStart_AutoTLS(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	require.NoError(t, listener.Close())
+
+	s, err := NewServer(slog.New(slog.NewTextHandler(os.Stdout, nil)), port, filepath.Join("..", "testdata", "commands.txt"), WithAutoTLS(nil))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error)
+	go func() {
+		errs <- s.Start(ctx)
+	}()
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, //nolint:gosec // the certificate is self-signed
+		},
+	}
+
+	var state *tls.ConnectionState
+	require.Eventually(t, func() bool {
+		resp, err := client.Get(fmt.Sprintf("https://localhost:%v/presentation", port))
+		if err != nil {
+			return false
+		}
+		_ = resp.Body.Close()
+		state = resp.TLS
+		return resp.StatusCode == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NotNil(t, state)
+	assert.NoError(t, state.PeerCertificates[0].VerifyHostname("localhost"))
+
+	cancel()
+	require.NoError(t, <-errs)
+}
@@ -0,0 +1,202 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joshjennings98/backend-demo/server/v2/types"
+)
+
+const (
+	TranscriptText = "text"
+	TranscriptJSON = "json"
+)
+
+// errStopped is why a command that the presenter stopped didn't finish
+var errStopped = errors.New("stopped")
+
+// transcriptEntry is a command that was run from the browser
+type transcriptEntry struct {
+	Slide    int       `json:"slide"`
+	Title    string    `json:"title,omitempty"`
+	Commands []string  `json:"commands"`
+	Client   string    `json:"client"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	ExitCode int       `json:"exit_code"`
+	// Error is why the command didn't exit by itself, e.g. it was stopped or hit a limit
+	Error  string `json:"error,omitempty"`
+	Output string `json:"output,omitempty"`
+}
+
+// captureWriter keeps the output of a command for the transcript
+type captureWriter struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (w *captureWriter) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+// take returns the output so far, without terminal escape sequences, and starts again
+func (w *captureWriter) take() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	output := ansiEscapeRegex.ReplaceAllString(w.buf.String(), "")
+	w.buf.Reset()
+	return output
+}
+
+// exitCode is the exit code of a command from the error it exited with, or -1 if it didn't exit by itself
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+
+	return -1
+}
+
+// transcriptFormat is the format of the transcript, from the settings or the extension of its file
+func transcriptFormat(settings types.TranscriptSettings) string {
+	if settings.Format != "" {
+		return settings.Format
+	}
+
+	switch filepath.Ext(settings.File) {
+	case ".json", ".jsonl":
+		return TranscriptJSON
+	default:
+		return TranscriptText
+	}
+}
+
+// transcriptCommands are the commands of a slide as they are run. The lines that processes that run together share
+// are shown once, followed by the command of each process named after it.
+func transcriptCommands(slide types.Slide) (commands []string) {
+	if len(slide.Processes) == 0 {
+		return slices.Clone(slide.ExecuteContent)
+	}
+
+	commands = slices.Clone(slide.ProcessSetup)
+	for _, process := range slide.Processes {
+		commands = append(commands, fmt.Sprintf("%v: %v", process.Name, process.Command))
+	}
+
+	return
+}
+
+// capture returns a writer to keep the output of a command for the transcript, or nil if it isn't needed
+func (c *commandManager) capture() *captureWriter {
+	if c.settings.Transcript.File == "" || !c.settings.Transcript.Output {
+		return nil
+	}
+
+	return &captureWriter{}
+}
+
+// transcribeRun appends a command that ran to the transcript, reason is why it didn't exit by itself if it didn't
+func (c *commandManager) transcribeRun(slide types.Slide, client string, start time.Time, err, reason error, capture *captureWriter) {
+	if c.settings.Transcript.File == "" {
+		return
+	}
+
+	entry := transcriptEntry{
+		Slide:    slide.ID,
+		Title:    slide.Title,
+		Commands: transcriptCommands(slide),
+		Client:   client,
+		Start:    start,
+		End:      time.Now(),
+		ExitCode: exitCode(err),
+	}
+
+	if reason == nil && entry.ExitCode == -1 && err != nil {
+		reason = err
+	}
+	if reason != nil {
+		entry.Error = reason.Error()
+	}
+	if capture != nil {
+		entry.Output = capture.take()
+	}
+
+	c.transcribe(entry)
+}
+
+// transcribe appends a command that was run to the transcript if there is one. The commands are redacted since the
+// transcript is likely to be shared after the presentation.
+func (c *commandManager) transcribe(entry transcriptEntry) {
+	path := c.settings.Transcript.File
+	if path == "" {
+		return
+	}
+
+	for i, command := range entry.Commands {
+		entry.Commands[i] = c.redactor.Redact(command)
+	}
+
+	c.transcriptMu.Lock()
+	defer c.transcriptMu.Unlock()
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err == nil {
+		if transcriptFormat(c.settings.Transcript) == TranscriptJSON {
+			err = json.NewEncoder(file).Encode(entry)
+		} else {
+			err = writeTranscriptText(file, entry)
+		}
+		err = errors.Join(err, file.Close())
+	}
+
+	if err != nil {
+		c.logger.Warn("could not write to transcript", "file", path, "error", err)
+	}
+}
+
+// writeTranscriptText writes an entry of the transcript for people to read, the commands are shown as they would be
+// typed with any output beneath them
+func writeTranscriptText(w io.Writer, entry transcriptEntry) (err error) {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "[%v] slide %v", entry.Start.Format(time.RFC3339), entry.Slide+1)
+	if entry.Title != "" {
+		fmt.Fprintf(&buf, " (%v)", entry.Title)
+	}
+	fmt.Fprintf(&buf, " run by %v\n", entry.Client)
+
+	for _, command := range entry.Commands {
+		fmt.Fprintf(&buf, "$ %v\n", command)
+	}
+
+	if output := strings.TrimSuffix(entry.Output, "\n"); output != "" {
+		fmt.Fprintln(&buf, output)
+	}
+
+	fmt.Fprintf(&buf, "exit code %v after %v", entry.ExitCode, entry.End.Sub(entry.Start).Round(time.Millisecond))
+	if entry.Error != "" {
+		fmt.Fprintf(&buf, ": %v", entry.Error)
+	}
+	buf.WriteString("\n\n")
+
+	_, err = w.Write(buf.Bytes())
+	return
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/joshjennings98/backend-demo/server/v2/types"
+)
+
+// readTranscript reads the entries of a JSON lines transcript
+func readTranscript(t *testing.T, path string) (entries []transcriptEntry) {
+	t.Helper()
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer func() { _ = file.Close() }()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry transcriptEntry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		entries = append(entries, entry)
+	}
+	require.NoError(t, scanner.Err())
+
+	return
+}
+
+func TestTranscriptFormat(t *testing.T) {
+	assert.Equal(t, TranscriptJSON, transcriptFormat(types.TranscriptSettings{File: "audit.jsonl"}))
+	assert.Equal(t, TranscriptJSON, transcriptFormat(types.TranscriptSettings{File: "audit.json"}))
+	assert.Equal(t, TranscriptText, transcriptFormat(types.TranscriptSettings{File: "transcript.log"}))
+	assert.Equal(t, TranscriptText, transcriptFormat(types.TranscriptSettings{File: "audit.jsonl", Format: TranscriptText}))
+}
+
+func TestTranscriptCommands(t *testing.T) {
+	assert.Equal(t, []string{"cd demo", "make"}, transcriptCommands(types.Slide{ExecuteContent: []string{"cd demo", "make"}}))
+
+	var slide types.Slide
+	slide.ProcessSetup, slide.Processes = parseProcesses("$ export PORT=8080\n$[server] ./serve --port $PORT\n$[client] curl localhost:$PORT")
+	assert.Equal(t, []string{"export PORT=8080", "server: ./serve --port $PORT", "client: curl localhost:$PORT"}, transcriptCommands(slide))
+}
+
+func TestWriteTranscriptText(t *testing.T) {
+	start := time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+	entry := transcriptEntry{
+		Slide:    2,
+		Title:    "Build",
+		Commands: []string{"cd demo", "make"},
+		Client:   "127.0.0.1:52000",
+		Start:    start,
+		End:      start.Add(1500 * time.Millisecond),
+		ExitCode: -1,
+		Error:    "stopped",
+		Output:   "building\n",
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeTranscriptText(&buf, entry))
+	assert.Equal(t, "[2024-05-01T09:30:00Z] slide 3 (Build) run by 127.0.0.1:52000\n$ cd demo\n$ make\nbuilding\nexit code -1 after 1.5s: stopped\n\n", buf.String())
+}
+
+func TestCommandManager_Transcript(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	settings := types.Settings{
+		Env:        map[string]string{"API_TOKEN": "s3cret-value"},
+		Redact:     types.RedactSettings{Env: []string{"API_TOKEN"}},
+		Transcript: types.TranscriptSettings{File: path, Output: true},
+	}
+	redactor, err := newRedactor(settings)
+	require.NoError(t, err)
+	cm := newCommandManager(logger, settings, redactor)
+
+	ws, cleanup := setupWebSocket(t, cm)
+	defer cleanup()
+
+	before := time.Now()
+	slide := types.Slide{ID: 1, Title: "Failing", ExecuteContent: []string{"echo token: s3cret-value", "printf '\\033[32mgreen\\033[0m\\n'", "exit 3"}}
+	require.NoError(t, cm.Run(slide, "127.0.0.1:52000"))
+	readTerminal(t, ws, "green")
+	assert.Eventually(t, func() bool { return !cm.IsRunning() }, 5*time.Second, 10*time.Millisecond)
+
+	entries := readTranscript(t, path)
+	require.Len(t, entries, 1)
+	entry := entries[0]
+	assert.Equal(t, 1, entry.Slide)
+	assert.Equal(t, "Failing", entry.Title)
+	assert.Equal(t, "127.0.0.1:52000", entry.Client)
+	assert.Equal(t, 3, entry.ExitCode)
+	assert.Empty(t, entry.Error)
+	assert.False(t, entry.Start.Before(before))
+	assert.False(t, entry.End.Before(entry.Start))
+
+	// secrets are redacted from the commands and the output, which doesn't have any escape sequences
+	assert.Equal(t, []string{"echo token: ********", "printf '\\033[32mgreen\\033[0m\\n'", "exit 3"}, entry.Commands)
+	assert.Equal(t, "token: ********\ngreen\n", entry.Output)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "s3cret")
+
+	t.Run("Stopped", func(t *testing.T) {
+		require.NoError(t, cm.Run(types.Slide{ID: 2, ExecuteContent: []string{"echo $((40 + 2))", "sleep 10"}}, "127.0.0.1:52001"))
+		readTerminal(t, ws, "42")
+		require.NoError(t, cm.Stop())
+		assert.Eventually(t, func() bool { return len(readTranscript(t, path)) == 2 }, 5*time.Second, 10*time.Millisecond)
+
+		entries := readTranscript(t, path)
+		assert.Equal(t, -1, entries[1].ExitCode)
+		assert.Equal(t, "stopped", entries[1].Error)
+	})
+
+	t.Run("Limit", func(t *testing.T) {
+		slide := types.Slide{ID: 3, Limits: types.Limits{Timeout: 50 * time.Millisecond}, ExecuteContent: []string{"sleep 10"}}
+		require.NoError(t, cm.Run(slide, ""))
+		assert.Eventually(t, func() bool { return len(readTranscript(t, path)) == 3 }, 5*time.Second, 10*time.Millisecond)
+
+		entries := readTranscript(t, path)
+		assert.Equal(t, "timeout limit of 50ms exceeded", entries[2].Error)
+	})
+
+	t.Run("Steps", func(t *testing.T) {
+		slide := types.Slide{ID: 4, Steps: true, StepCommands: []types.Step{{Command: "echo first"}, {Command: "false"}, {Hidden: []string{"cd /"}, Command: "echo last"}}}
+		for range slide.StepCommands {
+			require.NoError(t, cm.Run(slide, "127.0.0.1:52002"))
+			assert.Eventually(t, func() bool { return !cm.IsRunning() }, 5*time.Second, 10*time.Millisecond)
+		}
+
+		// each step has an entry of its own
+		entries := readTranscript(t, path)[3:]
+		require.Len(t, entries, 3)
+		for _, entry := range entries {
+			assert.Equal(t, "127.0.0.1:52002", entry.Client)
+		}
+		assert.Equal(t, []string{"echo first"}, entries[0].Commands)
+		assert.Equal(t, []string{"cd /", "echo last"}, entries[2].Commands)
+		assert.Equal(t, "first\n", entries[0].Output)
+		assert.Equal(t, 1, entries[1].ExitCode)
+		assert.Equal(t, "last\n", entries[2].Output)
+		assert.Zero(t, entries[2].ExitCode)
+	})
+}
+
+func TestCommandManager_TranscriptText(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	path := filepath.Join(t.TempDir(), "transcript.log")
+	cm := newCommandManager(logger, types.Settings{Transcript: types.TranscriptSettings{File: path}}, nil)
+
+	ws, cleanup := setupWebSocket(t, cm)
+	defer cleanup()
+
+	require.NoError(t, cm.Run(types.Slide{ID: 0, ExecuteContent: []string{"echo hello"}}, "127.0.0.1:52000"))
+	readTerminal(t, ws, "hello")
+	assert.Eventually(t, func() bool { return !cm.IsRunning() }, 5*time.Second, 10*time.Millisecond)
+
+	// the output is only kept when it is asked for
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Regexp(t, `^\[[^\]]+\] slide 1 run by 127.0.0.1:52000\n\$ echo hello\nexit code 0 after \S+\n\n$`, string(data))
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"io"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/joshjennings98/backend-demo/server/v2/types"
+)
+
+const (
+	defaultPrompt      = "$ "
+	defaultTypingSpeed = 40 * time.Millisecond
+)
+
+// parseTypewriter reads a slide's typewriter directive, which turns typing on or off or sets its speed, e.g.
+// <!-- typewriter: off --> or <!-- typewriter: 20ms -->
+func parseTypewriter(value string, typewriter types.Typewriter) (_ *types.Typewriter, err error) {
+	if typewriter.Enabled, err = parseSwitch(value); err != nil {
+		if typewriter.Speed, err = time.ParseDuration(value); err != nil {
+			return
+		}
+		typewriter.Enabled = true
+	}
+
+	return &typewriter, nil
+}
+
+// typewriter returns the typing settings for a slide, which can override the presentation's
+func (c *commandManager) typewriter(slide types.Slide) types.Typewriter {
+	if slide.Typewriter != nil {
+		return *slide.Typewriter
+	}
+
+	return c.settings.Typewriter
+}
+
+// typingDelay is how long to wait before typing the next character
+func typingDelay(typewriter types.Typewriter) time.Duration {
+	delay := cmp.Or(typewriter.Speed, defaultTypingSpeed)
+	if typewriter.Jitter > 0 {
+		delay += rand.N(2*typewriter.Jitter) - typewriter.Jitter
+	}
+
+	return max(delay, 0)
+}
+
+// typeCommand writes the prompt and each displayed line of a command a character at a time, it stops early if the
+// context is cancelled
+func typeCommand(ctx context.Context, w io.Writer, command string, typewriter types.Typewriter) (err error) {
+	prompt := cmp.Or(typewriter.Prompt, defaultPrompt)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for line := range strings.Lines(command) {
+		if _, err = io.WriteString(w, prompt); err != nil {
+			return
+		}
+
+		for _, char := range strings.TrimRight(line, "\n") {
+			timer.Reset(typingDelay(typewriter))
+			select {
+			case <-ctx.Done():
+				err = ctx.Err()
+				return
+			case <-timer.C:
+			}
+
+			if _, err = io.WriteString(w, string(char)); err != nil {
+				return
+			}
+		}
+
+		if _, err = io.WriteString(w, "\n"); err != nil {
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/joshjennings98/backend-demo/server/v2/types"
+)
+
+func TestParseTypewriter(t *testing.T) {
+	presentation := types.Typewriter{Enabled: true, Prompt: "> ", Speed: 10 * time.Millisecond}
+
+	testCases := []struct {
+		value    string
+		expected types.Typewriter
+		err      bool
+	}{
+		{value: "off", expected: types.Typewriter{Prompt: "> ", Speed: 10 * time.Millisecond}},
+		{value: "On", expected: presentation},
+		{value: "25ms", expected: types.Typewriter{Enabled: true, Prompt: "> ", Speed: 25 * time.Millisecond}},
+		{value: "fast", err: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			typewriter, err := parseTypewriter(tc.value, presentation)
+			if tc.err {
+				assert.Error(t, err)
+				assert.Nil(t, typewriter)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, *typewriter)
+		})
+	}
+}
+
+func TestTypingDelay(t *testing.T) {
+	assert.Equal(t, defaultTypingSpeed, typingDelay(types.Typewriter{}))
+
+	for range 100 {
+		delay := typingDelay(types.Typewriter{Speed: 10 * time.Millisecond, Jitter: 5 * time.Millisecond})
+		assert.GreaterOrEqual(t, delay, 5*time.Millisecond)
+		assert.Less(t, delay, 15*time.Millisecond)
+	}
+}
+
+func TestTypeCommand(t *testing.T) {
+	var buf bytes.Buffer
+	err := typeCommand(context.Background(), &buf, "ls -la\necho done", types.Typewriter{Speed: time.Microsecond})
+	require.NoError(t, err)
+	assert.Equal(t, "$ ls -la\n$ echo done\n", buf.String())
+
+	buf.Reset()
+	err = typeCommand(context.Background(), &buf, "ls", types.Typewriter{Prompt: "demo> ", Speed: time.Microsecond})
+	require.NoError(t, err)
+	assert.Equal(t, "demo> ls\n", buf.String())
+
+	t.Run("Stopped", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		var buf bytes.Buffer
+		err := typeCommand(ctx, &buf, "sleep 100", types.Typewriter{Speed: 15 * time.Millisecond})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, "$ s", buf.String())
+	})
+}
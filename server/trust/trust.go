@@ -0,0 +1,161 @@
+// Package trust keeps track of which presentations the user has allowed to run commands.
+// A presentation is identified by its absolute path and allowing it trusts the current contents only, of the commands
+// file and any other file that changes what it runs such as its .env file, so any change has to be allowed again.
+package trust
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+type Status int
+
+const (
+	// StatusUnknown is a presentation that hasn't been allowed or denied, or has changed since it was allowed
+	StatusUnknown Status = iota
+	StatusAllowed
+	StatusDenied
+)
+
+// Store is the set of allowed and denied presentations, persisted as JSON.
+type Store struct {
+	path    string
+	Allowed map[string]string `json:"allowed"` // absolute path to content hash
+	Denied  []string          `json:"denied"`
+}
+
+// DefaultPath is where the store is kept in the user's config directory.
+func DefaultPath() (path string, err error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		err = fmt.Errorf("could not find config directory for trust store: %w", err)
+		return
+	}
+
+	path = filepath.Join(dir, "backend-demo", "trust.json")
+	return
+}
+
+// Open loads the store from path, an empty store is returned if the file doesn't exist yet.
+func Open(path string) (store *Store, err error) {
+	store = &Store{
+		path:    path,
+		Allowed: map[string]string{},
+	}
+
+	contents, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		err = nil
+		return
+	}
+	if err != nil {
+		err = fmt.Errorf("could not read trust store '%v': %w", path, err)
+		return
+	}
+
+	if err = json.Unmarshal(contents, store); err != nil {
+		err = fmt.Errorf("could not parse trust store '%v': %w", path, err)
+		return
+	}
+
+	if store.Allowed == nil {
+		store.Allowed = map[string]string{}
+	}
+
+	return
+}
+
+// Hash returns the hash that is recorded when a presentation is allowed, from the contents of its commands file
+// followed by the other files that change what it runs. It is given the contents that were read to present it,
+// rather than reading the files again, so what is allowed is exactly what was shown.
+func Hash(contents []byte, others ...[]byte) string {
+	h := sha256.New()
+
+	// each file is preceded by its length so that moving lines from one to another changes the hash
+	for _, file := range append([][]byte{contents}, others...) {
+		fmt.Fprintf(h, "%d\n", len(file))
+		h.Write(file)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func identify(file string) (path string, err error) {
+	path, err = filepath.Abs(file)
+	if err != nil {
+		err = fmt.Errorf("could not resolve '%v': %w", file, err)
+	}
+
+	return
+}
+
+// Check returns whether the presentation, with the hash of its contents, has been allowed or denied.
+func (s *Store) Check(file, hash string) (status Status, err error) {
+	path, err := identify(file)
+	if err != nil {
+		return
+	}
+
+	switch {
+	case slices.Contains(s.Denied, path):
+		status = StatusDenied
+	case s.Allowed[path] == hash:
+		status = StatusAllowed
+	default:
+		status = StatusUnknown
+	}
+
+	return
+}
+
+// Allow trusts the presentation with the hash of its contents and saves the store.
+func (s *Store) Allow(file, hash string) (err error) {
+	path, err := identify(file)
+	if err != nil {
+		return
+	}
+
+	s.Denied = slices.DeleteFunc(s.Denied, func(denied string) bool { return denied == path })
+	s.Allowed[path] = hash
+	return s.save()
+}
+
+// Deny stops the presentation from running commands until it is allowed again and saves the store.
+func (s *Store) Deny(file string) (err error) {
+	path, err := identify(file)
+	if err != nil {
+		return
+	}
+
+	delete(s.Allowed, path)
+	if !slices.Contains(s.Denied, path) {
+		s.Denied = append(s.Denied, path)
+	}
+
+	return s.save()
+}
+
+func (s *Store) save() (err error) {
+	contents, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		err = fmt.Errorf("could not encode trust store: %w", err)
+		return
+	}
+
+	if err = os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		err = fmt.Errorf("could not create trust store directory: %w", err)
+		return
+	}
+
+	if err = os.WriteFile(s.path, contents, 0o600); err != nil {
+		err = fmt.Errorf("could not write trust store '%v': %w", s.path, err)
+	}
+
+	return
+}
@@ -0,0 +1,98 @@
+package trust
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHash(t *testing.T) {
+	commands := []byte("---\nenv_file: .env\n---\n$ echo $GREETING\n")
+
+	assert.Equal(t, Hash(commands, []byte("GREETING=hello\n")), Hash(commands, []byte("GREETING=hello\n")))
+	assert.NotEqual(t, Hash(commands), Hash(commands, []byte("GREETING=hello\n")))
+	assert.NotEqual(t, Hash(commands, []byte("GREETING=hello\n")), Hash(commands, []byte("GREETING=hello\nBASH_ENV=evil.sh\n")))
+	assert.NotEqual(t, Hash([]byte("a"), []byte("b")), Hash([]byte("a\n1\nb")))
+	assert.NotEqual(t, Hash([]byte("ab"), []byte("")), Hash([]byte("a"), []byte("b")))
+}
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	storePath := filepath.Join(dir, "config", "trust.json")
+	presentation := filepath.Join(dir, "commands.txt")
+	hash := Hash([]byte("$ echo hello\n"))
+
+	store, err := Open(storePath)
+	require.NoError(t, err)
+
+	status, err := store.Check(presentation, hash)
+	require.NoError(t, err)
+	assert.Equal(t, StatusUnknown, status)
+
+	require.NoError(t, store.Allow(presentation, hash))
+	status, err = store.Check(presentation, hash)
+	require.NoError(t, err)
+	assert.Equal(t, StatusAllowed, status)
+
+	t.Run("persisted", func(t *testing.T) {
+		reopened, err := Open(storePath)
+		require.NoError(t, err)
+		status, err := reopened.Check(presentation, hash)
+		require.NoError(t, err)
+		assert.Equal(t, StatusAllowed, status)
+
+		info, err := os.Stat(storePath)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	})
+
+	t.Run("relative path", func(t *testing.T) {
+		t.Chdir(dir)
+		status, err := store.Check("commands.txt", hash)
+		require.NoError(t, err)
+		assert.Equal(t, StatusAllowed, status)
+	})
+
+	t.Run("changed contents", func(t *testing.T) {
+		changed := Hash([]byte("$ rm -rf ~\n"))
+		status, err := store.Check(presentation, changed)
+		require.NoError(t, err)
+		assert.Equal(t, StatusUnknown, status)
+
+		require.NoError(t, store.Allow(presentation, changed))
+		status, err = store.Check(presentation, hash)
+		require.NoError(t, err)
+		assert.Equal(t, StatusUnknown, status)
+
+		require.NoError(t, store.Allow(presentation, hash))
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		require.NoError(t, store.Deny(presentation))
+		status, err := store.Check(presentation, hash)
+		require.NoError(t, err)
+		assert.Equal(t, StatusDenied, status)
+
+		reopened, err := Open(storePath)
+		require.NoError(t, err)
+		assert.Empty(t, reopened.Allowed)
+		assert.Len(t, reopened.Denied, 1)
+
+		require.NoError(t, store.Allow(presentation, hash))
+		status, err = store.Check(presentation, hash)
+		require.NoError(t, err)
+		assert.Equal(t, StatusAllowed, status)
+		assert.Empty(t, store.Denied)
+	})
+}
+
+func TestOpen_Invalid(t *testing.T) {
+	storePath := filepath.Join(t.TempDir(), "trust.json")
+	require.NoError(t, os.WriteFile(storePath, []byte("not json"), 0o600))
+
+	_, err := Open(storePath)
+	assert.ErrorContains(t, err, "could not parse trust store")
+}
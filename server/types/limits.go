@@ -0,0 +1,102 @@
+package types
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Limits stop runaway commands, a zero value means there is no limit.
+type Limits struct {
+	// Timeout is the wall-clock time a command can run for
+	Timeout time.Duration `yaml:"timeout"`
+	// CPU is the processor time each process a command starts can use
+	CPU time.Duration `yaml:"cpu"`
+	// Memory is the virtual memory each process a command starts can use
+	Memory ByteSize `yaml:"memory"`
+	// OpenFiles is the number of files each process a command starts can have open
+	OpenFiles int `yaml:"open_files"`
+	// MaxOutput is the amount of output a command can write before it is stopped
+	MaxOutput ByteSize `yaml:"max_output"`
+}
+
+// Override returns the limits with any that are set in other replacing them.
+func (l Limits) Override(other Limits) Limits {
+	if other.Timeout != 0 {
+		l.Timeout = other.Timeout
+	}
+	if other.CPU != 0 {
+		l.CPU = other.CPU
+	}
+	if other.Memory != 0 {
+		l.Memory = other.Memory
+	}
+	if other.OpenFiles != 0 {
+		l.OpenFiles = other.OpenFiles
+	}
+	if other.MaxOutput != 0 {
+		l.MaxOutput = other.MaxOutput
+	}
+
+	return l
+}
+
+// ByteSize is a number of bytes that can be written with a unit such as 512K, 256MB or 1GiB, the units are powers of 1024.
+type ByteSize int64
+
+var byteUnits = []struct {
+	suffixes []string
+	size     ByteSize
+}{
+	{suffixes: []string{"GIB", "GB", "G"}, size: 1 << 30},
+	{suffixes: []string{"MIB", "MB", "M"}, size: 1 << 20},
+	{suffixes: []string{"KIB", "KB", "K"}, size: 1 << 10},
+	{suffixes: []string{"B"}, size: 1},
+}
+
+func ParseByteSize(value string) (size ByteSize, err error) {
+	number, unit := strings.ToUpper(strings.TrimSpace(value)), ByteSize(1)
+	for _, byteUnit := range byteUnits {
+		if suffix := suffixOf(number, byteUnit.suffixes); suffix != "" {
+			number, unit = strings.TrimSpace(strings.TrimSuffix(number, suffix)), byteUnit.size
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n < 0 {
+		err = fmt.Errorf("invalid size '%v'", value)
+		return
+	}
+
+	size = ByteSize(n) * unit
+	return
+}
+
+func suffixOf(value string, suffixes []string) string {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(value, suffix) {
+			return suffix
+		}
+	}
+
+	return ""
+}
+
+func (b *ByteSize) UnmarshalYAML(value *yaml.Node) (err error) {
+	*b, err = ParseByteSize(value.Value)
+	return
+}
+
+func (b ByteSize) String() string {
+	for _, byteUnit := range byteUnits {
+		if b >= byteUnit.size && b%byteUnit.size == 0 {
+			return fmt.Sprintf("%v%v", int64(b/byteUnit.size), byteUnit.suffixes[len(byteUnit.suffixes)-1])
+		}
+	}
+
+	return fmt.Sprintf("%vB", int64(b))
+}
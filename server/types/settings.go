@@ -0,0 +1,118 @@
+package types
+
+// Settings are the presentation-level options that can be set in the front matter of a commands file.
+type Settings struct {
+	Title      string            `yaml:"title"`
+	Theme      string            `yaml:"theme"`
+	Shell      string            `yaml:"shell"`
+	WorkingDir string            `yaml:"dir"`
+	Env        map[string]string `yaml:"env"`
+	// EnvFile is a .env file with more environment variables for commands, those in Env take precedence
+	EnvFile string `yaml:"env_file"`
+	// CleanEnv runs commands without the server's environment, except for the variables in InheritEnv
+	CleanEnv   bool               `yaml:"clean_env"`
+	InheritEnv []string           `yaml:"inherit_env"`
+	Terminal   TerminalSettings   `yaml:"terminal"`
+	Port       int                `yaml:"port"`
+	Listen     ListenSettings     `yaml:"listen"`
+	Auth       bool               `yaml:"auth"`
+	TLS        TLSSettings        `yaml:"tls"`
+	Assets     AssetSettings      `yaml:"assets"`
+	Setup      []string           `yaml:"setup"`
+	Teardown   []string           `yaml:"teardown"`
+	Limits     Limits             `yaml:"limits"`
+	Redact     RedactSettings     `yaml:"redact"`
+	Typewriter Typewriter         `yaml:"typewriter"`
+	Record     RecordSettings     `yaml:"record"`
+	Fallback   FallbackSettings   `yaml:"fallback"`
+	Transcript TranscriptSettings `yaml:"transcript"`
+	// ExecutionDisabled presents the slides without running any commands, it can't be set from the front matter
+	// since it is how untrusted commands files are shown
+	ExecutionDisabled bool `yaml:"-"`
+	// Safe can't be set from the front matter either since it is for previewing presentations that aren't trusted
+	Safe SafeSettings `yaml:"-"`
+	// Replay plays a recorded session back in the browser instead of presenting, it is set when a session is replayed
+	Replay bool `yaml:"-"`
+}
+
+type TerminalSettings struct {
+	FontSize   int    `yaml:"font_size" json:"font_size,omitempty"`
+	FontFamily string `yaml:"font_family" json:"font_family,omitempty"`
+	Scrollback int    `yaml:"scrollback" json:"scrollback,omitempty"`
+}
+
+type ListenSettings struct {
+	// Address is the host or IP address to bind to, it defaults to localhost
+	Address string `yaml:"address"`
+	// Socket is the path of a unix domain socket to listen on instead of a TCP port
+	Socket string `yaml:"socket"`
+	// AllowedOrigins are the origins, other than localhost, that can open a websocket connection
+	AllowedOrigins []string `yaml:"allowed_origins"`
+}
+
+type TLSSettings struct {
+	Cert string `yaml:"cert"`
+	Key  string `yaml:"key"`
+	// Auto generates and caches a self-signed certificate for Hosts when no certificate is given
+	Auto  bool     `yaml:"auto"`
+	Hosts []string `yaml:"hosts"`
+}
+
+func (t TLSSettings) Enabled() bool {
+	return t.Cert != "" || t.Auto
+}
+
+// AssetSettings are the files next to the commands file that can be served in addition to those embedded in slides.
+type AssetSettings struct {
+	Dir   string   `yaml:"dir" json:"dir,omitempty"`
+	Allow []string `yaml:"allow" json:"allow,omitempty"`
+}
+
+// RedactSettings are the secrets that are masked in slides and command output before they are sent to the browser.
+type RedactSettings struct {
+	// Env are the environment variables whose values are secret
+	Env []string `yaml:"env"`
+	// EnvFiles are .env files whose values are all secret
+	EnvFiles []string `yaml:"env_files"`
+	// Patterns are regular expressions for secrets such as bearer tokens or AWS keys
+	Patterns []string `yaml:"patterns"`
+}
+
+// RecordSettings record what commands send to the terminal as asciicast files, and the whole presentation as a session.
+type RecordSettings struct {
+	// Dir is where the recordings are written, named by slide and time, nothing is recorded when it is empty
+	Dir string `yaml:"dir"`
+	// Session is a file to record the whole presentation to, as a timeline of the slides shown and the terminal output,
+	// so it can be replayed
+	Session string `yaml:"session"`
+}
+
+// FallbackSettings keep the output of the last successful run of each command so it can be replayed instead if the
+// command fails during the presentation, e.g. because the network is down.
+type FallbackSettings struct {
+	// Record keeps the output of each successful run, replacing the one before
+	Record bool `yaml:"record"`
+	// Dir is where the output is kept, it defaults to .backend-demo next to the commands file
+	Dir string `yaml:"dir"`
+	// Replay is offer to show a button that replays the output when a command fails, auto to replay it straight away
+	// or off, it defaults to offer
+	Replay string `yaml:"replay"`
+}
+
+// TranscriptSettings keep a log of every command run from the browser, for after the presentation and as an audit trail
+// of who ran what.
+type TranscriptSettings struct {
+	// File is appended to, nothing is logged when it is empty
+	File string `yaml:"file"`
+	// Format is text or json, for JSON lines, it defaults to json for .json and .jsonl files and text otherwise
+	Format string `yaml:"format"`
+	// Output includes the output of the commands
+	Output bool `yaml:"output"`
+}
+
+// SafeSettings sanitize the HTML of slides so that presentations from elsewhere can be previewed.
+type SafeSettings struct {
+	Enabled bool
+	// IframeHosts are the only hosts that embedded iframes can be loaded from
+	IframeHosts []string
+}
@@ -1,16 +1,81 @@
 package types
 
+import "time"
+
 type SlideType = int
 
 const (
 	SlideTypePlain SlideType = iota
 	SlideTypeCodeblock
 	SlideTypeCommand
+	// SlideTypePlayback replays a recording into the terminal instead of running a command
+	SlideTypePlayback
 )
 
 type Slide struct {
 	ID             int
+	Title          string
 	Content        string
 	ExecuteContent []string
-	SlideType      SlideType
+	// Commands are the lines of ExecuteContent with any secrets masked, for showing and searching them
+	Commands  []string
+	SlideType SlideType
+	// Assets are the local files embedded in the slide, relative to the presentation directory
+	Assets []string
+	// Limits override the presentation's limits for the slide's command
+	Limits Limits
+	// WorkingDir and Env override the presentation's working directory and add environment variables for the slide's command
+	WorkingDir string
+	Env        map[string]string
+	// Shell overrides the presentation's shell, or interpreter such as python3, for the slide's command
+	Shell string
+	// Typewriter overrides whether the presentation types the slide's command before running it
+	Typewriter *Typewriter
+	// Steps runs the lines of the command one at a time, each time the command is executed, in the same shell
+	Steps bool
+	// StepCommands are what each step of a step-through slide runs
+	StepCommands []Step
+	// Processes are the commands that run together, rather than ExecuteContent, when there are any. ProcessSetup is
+	// the slide's other lines, which run once before the processes start.
+	Processes    []Process
+	ProcessSetup []string
+	// Recording is the asciicast file that a playback slide replays
+	Recording string
+	Playback  Playback
+}
+
+// Step is a line shown on a step-through slide, which runs with the hidden lines before it.
+type Step struct {
+	Hidden  []string
+	Command string
+}
+
+// Process is one of the commands of a slide that run together, its output is prefixed with its name.
+type Process struct {
+	Name    string
+	Command string
+}
+
+// Playback is how the recording of a playback slide is replayed.
+type Playback struct {
+	// Speed multiplies the speed of the recording, it defaults to 1
+	Speed float64
+	// IdleLimit shortens any pause in the recording that is longer than it
+	IdleLimit time.Duration
+}
+
+// PlaybackState is how the recording of a playback slide is being played back, which the presenter can change.
+type PlaybackState struct {
+	Speed  float64
+	Paused bool
+}
+
+// CommandResult is how the last run of a slide's command ended.
+type CommandResult struct {
+	// LimitHit describes the limit that stopped the command, if it was stopped by one
+	LimitHit string
+	// CanReplay is set when the command failed and there is output from its last successful run to replay instead
+	CanReplay bool
+	// Replayed is set when the output is a replay of the last successful run rather than from the command itself
+	Replayed bool
 }
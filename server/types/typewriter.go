@@ -0,0 +1,14 @@
+package types
+
+import "time"
+
+// Typewriter types the prompt and command into the terminal before a command slide runs, as in a real terminal session.
+type Typewriter struct {
+	Enabled bool `yaml:"enabled"`
+	// Prompt is typed before each line of the command, it defaults to "$ "
+	Prompt string `yaml:"prompt"`
+	// Speed is the delay between characters, it defaults to 40ms
+	Speed time.Duration `yaml:"speed"`
+	// Jitter varies the delay between characters by up to this much either way so the typing looks less mechanical
+	Jitter time.Duration `yaml:"jitter"`
+}